@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"verify-golang/util"
+)
+
+// ABI decoding of constructor arguments,
+// https://docs.soliditylang.org/en/latest/abi-spec.html#formal-specification-of-the-encoding
+
+var ErrAbiDataTooShort = errors.New("abi: data too short")
+
+// AbiParameter is one input of an ABI entry
+type AbiParameter struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Components []AbiParameter `json:"components,omitempty"`
+}
+
+// AbiArgument is a decoded value. Integers are decimal strings, bytes are 0x hex,
+// arrays are lists of values and tuples are lists of AbiArgument
+type AbiArgument struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// constructorInputs returns the inputs of the constructor entry, false when the ABI has none
+func constructorInputs(abi []any) ([]AbiParameter, bool) {
+	for _, item := range abi {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		var entry struct {
+			Type   string         `json:"type"`
+			Inputs []AbiParameter `json:"inputs"`
+		}
+		if json.Unmarshal(raw, &entry) == nil && entry.Type == "constructor" {
+			return entry.Inputs, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeConstructorArgs decodes hex encoded constructor arguments against the constructor of abi
+func DecodeConstructorArgs(abi []any, args string) ([]AbiArgument, error) {
+	inputs, ok := constructorInputs(abi)
+	if !ok || len(inputs) == 0 {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(util.TrimHex(args))
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeAbiTuple(data, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return abiArguments(inputs, values), nil
+}
+
+// decodedConstructorArgs is DecodeConstructorArgs for responses, failures are logged and leave the field empty
+func decodedConstructorArgs(abi []any, args string) []AbiArgument {
+	if args == "" {
+		return nil
+	}
+	arguments, err := DecodeConstructorArgs(abi, args)
+	if err != nil {
+		util.Logger().Error(fmt.Errorf("decode constructor args %s failed: %v", args, err))
+		return nil
+	}
+	return arguments
+}
+
+func abiArguments(params []AbiParameter, values []any) []AbiArgument {
+	arguments := make([]AbiArgument, len(params))
+	for i, param := range params {
+		arguments[i] = AbiArgument{Name: param.Name, Type: abiTypeName(param), Value: values[i]}
+	}
+	return arguments
+}
+
+// abiTypeName expands tuple types to their components, "tuple(uint8,bytes)[]"
+func abiTypeName(param AbiParameter) string {
+	if !strings.HasPrefix(param.Type, "tuple") {
+		return param.Type
+	}
+	components := make([]string, len(param.Components))
+	for i, component := range param.Components {
+		components[i] = abiTypeName(component)
+	}
+	return "(" + strings.Join(components, ",") + ")" + strings.TrimPrefix(param.Type, "tuple")
+}
+
+// abiArrayElem splits "T[k]" or "T[]" into the element parameter and k, -1 for dynamic arrays
+func abiArrayElem(param AbiParameter) (AbiParameter, int, bool, error) {
+	if !strings.HasSuffix(param.Type, "]") {
+		return param, 0, false, nil
+	}
+	open := strings.LastIndex(param.Type, "[")
+	if open < 0 {
+		return param, 0, false, fmt.Errorf("abi: invalid type %s", param.Type)
+	}
+	elem := AbiParameter{Type: param.Type[:open], Components: param.Components}
+	size := param.Type[open+1 : len(param.Type)-1]
+	if size == "" {
+		return elem, -1, true, nil
+	}
+	length, err := strconv.Atoi(size)
+	if err != nil || length < 0 {
+		return param, 0, false, fmt.Errorf("abi: invalid array size in %s", param.Type)
+	}
+	return elem, length, true, nil
+}
+
+func abiIsDynamic(param AbiParameter) bool {
+	elem, length, isArray, err := abiArrayElem(param)
+	switch {
+	case err != nil:
+		return false
+	case isArray:
+		return length < 0 || abiIsDynamic(elem)
+	case param.Type == "string" || param.Type == "bytes":
+		return true
+	case param.Type == "tuple":
+		for _, component := range param.Components {
+			if abiIsDynamic(component) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// abiHeadSize is the number of bytes a parameter takes in the head of its enclosing tuple
+func abiHeadSize(param AbiParameter) int {
+	if abiIsDynamic(param) {
+		return 32
+	}
+	if elem, length, isArray, _ := abiArrayElem(param); isArray {
+		return length * abiHeadSize(elem)
+	}
+	if param.Type == "tuple" {
+		var size int
+		for _, component := range param.Components {
+			size += abiHeadSize(component)
+		}
+		return size
+	}
+	return 32
+}
+
+func abiWord(data []byte, offset int) ([]byte, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return nil, ErrAbiDataTooShort
+	}
+	return data[offset : offset+32], nil
+}
+
+// abiInt reads a word used as an offset or length, which must fit in the data
+func abiInt(data []byte, offset int) (int, error) {
+	word, err := abiWord(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	value := new(big.Int).SetBytes(word)
+	if !value.IsInt64() || value.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("abi: offset or length %s out of range", value)
+	}
+	return int(value.Int64()), nil
+}
+
+func decodeAbiTuple(data []byte, params []AbiParameter) ([]any, error) {
+	values := make([]any, len(params))
+	head := 0
+	for i, param := range params {
+		var err error
+		if abiIsDynamic(param) {
+			var offset int
+			if offset, err = abiInt(data, head); err != nil {
+				return nil, err
+			}
+			values[i], err = decodeAbiValue(data[offset:], param)
+		} else {
+			if head > len(data) {
+				return nil, ErrAbiDataTooShort
+			}
+			values[i], err = decodeAbiValue(data[head:], param)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", param.Name, err)
+		}
+		head += abiHeadSize(param)
+	}
+	return values, nil
+}
+
+func decodeAbiValue(data []byte, param AbiParameter) (any, error) {
+	elem, length, isArray, err := abiArrayElem(param)
+	if err != nil {
+		return nil, err
+	}
+	if isArray {
+		if length < 0 {
+			if length, err = abiInt(data, 0); err != nil {
+				return nil, err
+			}
+			data = data[32:]
+		}
+		// every element takes at least one head slot, reject lengths the data cannot hold
+		if length*max(abiHeadSize(elem), 1) > len(data) {
+			return nil, ErrAbiDataTooShort
+		}
+		params := make([]AbiParameter, length)
+		for i := range params {
+			params[i] = elem
+		}
+		return decodeAbiTuple(data, params)
+	}
+
+	switch {
+	case param.Type == "tuple":
+		values, err := decodeAbiTuple(data, param.Components)
+		if err != nil {
+			return nil, err
+		}
+		return abiArguments(param.Components, values), nil
+	case param.Type == "string" || param.Type == "bytes":
+		size, err := abiInt(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		if 32+size > len(data) {
+			return nil, ErrAbiDataTooShort
+		}
+		if param.Type == "string" {
+			return string(data[32 : 32+size]), nil
+		}
+		return "0x" + hex.EncodeToString(data[32:32+size]), nil
+	}
+
+	word, err := abiWord(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case param.Type == "address":
+		return util.ChecksumAddress(hex.EncodeToString(word[12:])), nil
+	case param.Type == "bool":
+		return word[31] != 0, nil
+	case param.Type == "function":
+		return "0x" + hex.EncodeToString(word[:24]), nil
+	case strings.HasPrefix(param.Type, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(param.Type, "bytes"))
+		if err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("abi: invalid type %s", param.Type)
+		}
+		return "0x" + hex.EncodeToString(word[:size]), nil
+	case strings.HasPrefix(param.Type, "uint"):
+		return new(big.Int).SetBytes(word).String(), nil
+	case strings.HasPrefix(param.Type, "int"):
+		value := new(big.Int).SetBytes(word)
+		// two's complement over the full word, solc sign extends smaller ints
+		if word[0]&0x80 != 0 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return value.String(), nil
+	}
+	return nil, fmt.Errorf("abi: unsupported type %s", param.Type)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConstructorAbi = `[
+  {"type": "function", "name": "owner", "inputs": [], "outputs": [{"name": "", "type": "address"}]},
+  {"type": "constructor", "inputs": [
+    {"name": "owner", "type": "address"},
+    {"name": "ids", "type": "uint256[]"},
+    {"name": "name", "type": "string"},
+    {"name": "cfg", "type": "tuple", "components": [{"name": "a", "type": "uint8"}, {"name": "b", "type": "bytes"}]},
+    {"name": "delta", "type": "int16"},
+    {"name": "sel", "type": "bytes4"},
+    {"name": "flag", "type": "bool"},
+    {"name": "pair", "type": "uint256[2]"}
+  ]}
+]`
+
+func abiTestWord(value string) string {
+	return fmt.Sprintf("%064s", value)
+}
+
+func TestDecodeConstructorArgs(t *testing.T) {
+	var abi []any
+	if err := json.Unmarshal([]byte(testConstructorAbi), &abi); err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Join([]string{
+		abiTestWord("5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"),
+		abiTestWord("120"),             // ids at 288
+		abiTestWord("180"),             // name at 384
+		abiTestWord("1c0"),             // cfg at 448
+		strings.Repeat("f", 62) + "fe", // -2
+		"12345678" + strings.Repeat("0", 56),
+		abiTestWord("1"),
+		abiTestWord("3"),
+		abiTestWord("4"),
+		// ids
+		abiTestWord("2"), abiTestWord("1"), abiTestWord("2"),
+		// name
+		abiTestWord("5"), hex.EncodeToString([]byte("hello")) + strings.Repeat("0", 54),
+		// cfg, b is relative to the tuple
+		abiTestWord("7"), abiTestWord("40"), abiTestWord("3"), "010203" + strings.Repeat("0", 58),
+	}, "")
+
+	arguments, err := DecodeConstructorArgs(abi, "0x"+args)
+	if err != nil {
+		t.Fatalf("DecodeConstructorArgs failed: %v", err)
+	}
+	expected := []AbiArgument{
+		{Name: "owner", Type: "address", Value: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{Name: "ids", Type: "uint256[]", Value: []any{"1", "2"}},
+		{Name: "name", Type: "string", Value: "hello"},
+		{Name: "cfg", Type: "(uint8,bytes)", Value: []AbiArgument{
+			{Name: "a", Type: "uint8", Value: "7"},
+			{Name: "b", Type: "bytes", Value: "0x010203"},
+		}},
+		{Name: "delta", Type: "int16", Value: "-2"},
+		{Name: "sel", Type: "bytes4", Value: "0x12345678"},
+		{Name: "flag", Type: "bool", Value: true},
+		{Name: "pair", Type: "uint256[2]", Value: []any{"3", "4"}},
+	}
+	if !reflect.DeepEqual(arguments, expected) {
+		t.Errorf("DecodeConstructorArgs = %+v; want %+v", arguments, expected)
+	}
+
+	// truncated data and out of range offsets are errors, not panics
+	for _, bad := range []string{args[:len(args)-64], abiTestWord("5aaeb6053f3e94c9b9a09f33669435e7ef1beaed") + abiTestWord("ffffffff")} {
+		if _, err = DecodeConstructorArgs(abi, bad); err == nil {
+			t.Errorf("DecodeConstructorArgs(%s) expected error", bad)
+		}
+	}
+
+	// no constructor, nothing to decode
+	if arguments, err = DecodeConstructorArgs(abi[:1], args); err != nil || arguments != nil {
+		t.Errorf("unexpected result %v %v", arguments, err)
+	}
+}
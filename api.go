@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"verify-golang/util"
@@ -16,9 +18,11 @@ const (
 )
 
 var (
-	ErrBytecodeNotFound       = errors.New("address not a contract or bytecode not found")
-	InvalidValidInputMetadata = errors.New("invalid metadata")
-	InvalidValidAddress       = errors.New("invalid address")
+	ErrBytecodeNotFound        = errors.New("address not a contract or bytecode not found")
+	InvalidValidInputMetadata  = errors.New("invalid metadata")
+	InvalidValidAddress        = errors.New("invalid address")
+	InvalidContractName        = errors.New(`invalid contractName, expected "path/File.sol:Name"`)
+	ErrCompilerVersionRequired = errors.New("compilerVersion is required, it could not be detected from the on-chain bytecode")
 )
 
 type VerificationRequest struct {
@@ -26,92 +30,261 @@ type VerificationRequest struct {
 	Metadata        string `json:"metadata"`
 	Chain           int64  `json:"chain"`
 	CompilerVersion string `json:"compilerVersion"`
+	// StandardJson is a raw solc or vyper standard JSON input, used instead of Metadata together with ContractName
+	StandardJson string `json:"standardJson,omitempty"`
+	// ContractName selects the contract to verify from StandardJson, in the form "path/File.sol:Name",
+	// vyper sources may be given by path alone
+	ContractName string `json:"contractName,omitempty"`
+	// ConstructorArgs is optional, the abi encoded arguments supplied by the user
+	ConstructorArgs string `json:"constructorArgs,omitempty"`
+	// Search recompiles with other optimizer, evmVersion, viaIR and patch version settings after a mismatch
+	Search bool `json:"search,omitempty"`
+	// VerifyImplementation verifies the implementation when the address is a proxy, minimal proxies always are
+	VerifyImplementation bool `json:"verifyImplementation,omitempty"`
+	// Block is the block number, as a number or hex string, or the block hash to read the code at, latest by default
+	Block json.RawMessage `json:"block,omitempty"`
+	// CreationTx is the transaction that created the contract, read from the chain for its creation code
+	CreationTx string `json:"creationTx,omitempty"`
+
+	// creationCode is set when the address has no code, the creation code is compared instead
+	creationCode string
+	// creationInput is the creation code read for the constructor arguments, once for all search candidates
+	creationInput *creationInputCache
+	// implementation is set when Address is a proxy verified through it, its code and creation are read instead
+	implementation string
 }
 
 type VerificationResponse struct {
-	VerifiedStatus         string        `json:"verified_status"`
-	Message                string        `json:"message"`
-	Abi                    []interface{} `json:"abi,omitempty"`
-	CreationBytecodeLength int           `json:"creation_bytecode_length"`
-	ReviveVersion          string        `json:"revive_version,omitempty"`
-	ContractName           string        `json:"contract_name,omitempty"`
+	VerifiedStatus         string            `json:"verified_status"`
+	Message                string            `json:"message"`
+	Abi                    []interface{}     `json:"abi,omitempty"`
+	CreationBytecodeLength int               `json:"creation_bytecode_length"`
+	ReviveVersion          string            `json:"revive_version,omitempty"`
+	ContractName           string            `json:"contract_name,omitempty"`
+	CompilerVersion        string            `json:"compiler_version,omitempty"`
+	OnchainMetadata        *BytecodeMetadata `json:"onchain_metadata,omitempty"`
+	Match                  *MatchDetails     `json:"match,omitempty"`
+	// Immutables maps the AST id of each immutable to its on-chain value
+	Immutables map[string]string `json:"immutables,omitempty"`
+	// ConstructorArgs are the arguments found in the creation code
+	ConstructorArgs        string        `json:"constructor_args,omitempty"`
+	DecodedConstructorArgs []AbiArgument `json:"decoded_constructor_args,omitempty"`
+	// UnverifiedConstructorArgs echoes the constructorArgs supplied when the creation code was not compared
+	UnverifiedConstructorArgs string `json:"unverified_constructor_args,omitempty"`
+	// Libraries maps each linked library, "file:Lib", to its address
+	Libraries map[string]string `json:"libraries,omitempty"`
+	// Diagnostics explains a bytecode mismatch, closest candidate first
+	Diagnostics []MismatchDiagnostic `json:"diagnostics,omitempty"`
+	// MatchedSettings are the compiler settings a search found, when they differ from the submitted ones
+	MatchedSettings *SearchSettings `json:"matched_settings,omitempty"`
+	// Proxy is set when the requested address is a proxy, the verified contract is then its implementation
+	// if the proxy is a minimal one or verifyImplementation was set
+	Proxy *ProxyInfo `json:"proxy,omitempty"`
+	// CreationMatch tells that the address had no code and the creation code was compared instead
+	CreationMatch bool `json:"creation_match,omitempty"`
+	// CreationTx is the creation transaction the creation code was read from, when read from the chain
+	CreationTx string `json:"creation_tx,omitempty"`
 }
 
 // https://ardislu.dev/solc-standard-json-input-from-metadata
 func verificationHandler(w http.ResponseWriter, r *http.Request) {
-	var req VerificationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if req.Metadata == "" || req.Address == "" || req.CompilerVersion == "" || req.Chain < 0 {
-		respondError(w, InvalidValidInputMetadata)
-		return
-	}
-	if !util.VerifyEthereumAddress(req.Address) {
-		respondError(w, InvalidValidAddress)
+	if isSourcifyRequest(r, body) {
+		sourcifyVerifyHandler(w, r, body)
 		return
 	}
 
-	if !strings.HasPrefix(req.CompilerVersion, "v") {
-		req.CompilerVersion = "v" + req.CompilerVersion
+	var req VerificationRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	ctx := r.Context()
-	if err := SolcManagerInstance.EnsureVersion(req.CompilerVersion); err != nil {
+	if err := req.validate(); err != nil {
 		respondError(w, err)
 		return
 	}
 
-	// get bytecode from chain
-	chainBytecode, err := req.fetchChainBytecode(ctx)
+	resp, err := req.verify(r.Context(), nil)
 	if err != nil {
 		respondError(w, err)
 		return
 	}
-	if chainBytecode == "" {
-		respondError(w, ErrBytecodeNotFound)
-		return
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// validate checks the required fields and normalizes the compiler version
+func (v *VerificationRequest) validate() error {
+	if v.input() == "" || v.Address == "" || v.Chain < 0 {
+		return InvalidValidInputMetadata
+	}
+	if !util.VerifyEthereumAddress(v.Address) {
+		return InvalidValidAddress
+	}
+	if _, err := v.blockParam(); err != nil {
+		return err
+	}
+	if v.CreationTx != "" && !creationTxRegex.MatchString(v.CreationTx) {
+		return ErrInvalidCreationTx
+	}
+	// an empty version is detected from the on-chain metadata later
+	if v.CompilerVersion != "" && !strings.HasPrefix(v.CompilerVersion, "v") {
+		v.CompilerVersion = "v" + v.CompilerVersion
+	}
+	return nil
+}
+
+// input returns the submitted compiler input, either metadata or standard JSON
+func (v *VerificationRequest) input() string {
+	if v.StandardJson != "" {
+		return v.StandardJson
+	}
+	return v.Metadata
+}
+
+// verify runs the compile and compare pipeline for a validated request,
+// progress is optional and receives the current stage of the pipeline
+func (v *VerificationRequest) verify(ctx context.Context, progress func(stage string)) (*VerificationResponse, error) {
+	if progress == nil {
+		progress = func(string) {}
 	}
+	v.creationInput = &creationInputCache{}
 
-	inputJson, err := req.VerifyMetadata()
+	if contract := v.lookupVerified(v.Address); contract != nil {
+		return contract.response("already verified"), nil
+	}
+
+	// get bytecode from chain
+	chainBytecode, err := v.fetchChainBytecode(ctx)
 	if err != nil {
-		respondError(w, err)
-		return
+		return nil, err
+	}
+	var proxy *ProxyInfo
+	if util.TrimHex(chainBytecode) == "" {
+		// self-destructed, or not deployed yet at the requested block
+		if err = v.fetchCreationCode(ctx); err != nil {
+			return nil, err
+		}
+		chainBytecode = ""
+	}
+	if v.creationCode == "" {
+		if chainBytecode, proxy, err = v.resolveProxy(ctx, chainBytecode); err != nil {
+			return nil, err
+		}
 	}
-	util.Logger().Info(fmt.Sprintf("start compile contract %s with version %s", req.Address, req.CompilerVersion))
-	compiledOutput, err := inputJson.recompileContract(ctx, req.CompilerVersion)
+	if v.implementation != "" {
+		if contract := v.lookupVerified(v.implementation); contract != nil {
+			v.saveProxyRecord(*contract, proxy)
+			resp := contract.response("already verified")
+			resp.Proxy = proxy
+			return resp, nil
+		}
+	}
+
+	inputJson, err := v.VerifyMetadata()
 	if err != nil {
-		util.Logger().Error(fmt.Errorf("compile contract %s with version %s failed: %s", req.Address, req.CompilerVersion, err.Error()))
-		respondError(w, err)
-		return
+		return nil, err
+	}
+	// pvm code carries no solc CBOR metadata
+	onchainMetadata, _ := DecodeBytecodeMetadata(chainBytecode)
+	if _, isRevive := inputJson.(*ReviveMetadata); isRevive {
+		onchainMetadata = nil
+	}
+	if err = v.checkCompilerVersion(onchainMetadata); err != nil {
+		return nil, err
 	}
 
-	verified, err := req.compareBytecodes(ctx, chainBytecode, compiledOutput)
+	progress(jobCompiling)
+	if _, isVyper := inputJson.(*VyperMetadata); isVyper {
+		err = VyperManagerInstance.EnsureVersion(v.CompilerVersion)
+	} else {
+		err = SolcManagerInstance.EnsureVersion(v.CompilerVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	util.Logger().Info(fmt.Sprintf("start compile contract %s with version %s", v.Address, v.CompilerVersion))
+	compiledOutput, err := inputJson.recompileContract(ctx, v.CompilerVersion)
+	if err != nil {
+		util.Logger().Error(fmt.Errorf("compile contract %s with version %s failed: %s", v.Address, v.CompilerVersion, err.Error()))
+		return nil, err
+	}
 
+	progress(jobComparing)
+	verified, err := v.compareBytecodes(ctx, chainBytecode, compiledOutput)
 	if err != nil {
-		respondError(w, err)
-		return
+		return nil, err
 	}
 
+	var matchedSettings *SearchSettings
+	if verified.Status == mismatch && v.Search {
+		progress(jobSearching)
+		result, searched, err := v.searchMismatch(ctx, chainBytecode, onchainMetadata)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			return nil, &MismatchError{Diagnostics: verified.Diagnostics, Searched: searched, Proxy: proxy}
+		}
+		compiledOutput, verified, matchedSettings = result.output, result.match, &result.settings
+	}
 	if verified.Status == mismatch {
-		respondError(w, fmt.Errorf("bytecode mismatch"))
-		return
+		return nil, &MismatchError{Diagnostics: verified.Diagnostics, Proxy: proxy}
+	}
+	contract := compiledOutput.Contracts[compiledOutput.CompileTarget][compiledOutput.ContractName]
+	if verified.Creation {
+		onchainMetadata = creationMetadata(v.creationCode, contract.Evm.Bytecode.Object)
 	}
+	verified.checkMetadataHash(onchainMetadata, contract.Metadata)
+	v.saveVerified(inputJson, compiledOutput, verified, proxy)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(VerificationResponse{VerifiedStatus: verified.Status,
-		Message:                "ok",
-		Abi:                    compiledOutput.Contracts[compiledOutput.CompileTarget][compiledOutput.ContractName].Abi,
-		CreationBytecodeLength: len(compiledOutput.Contracts[compiledOutput.CompileTarget][compiledOutput.ContractName].Evm.Bytecode.Object),
-		ReviveVersion:          compiledOutput.ReviveVersion,
-		ContractName:           compiledOutput.ContractName,
-	})
+	return &VerificationResponse{VerifiedStatus: verified.Status,
+		Message:                   "ok",
+		Abi:                       contract.Abi,
+		CreationBytecodeLength:    len(contract.Evm.Bytecode.Object),
+		ReviveVersion:             compiledOutput.ReviveVersion,
+		ContractName:              compiledOutput.ContractName,
+		CompilerVersion:           v.CompilerVersion,
+		OnchainMetadata:           onchainMetadata,
+		Match:                     verified.Details,
+		Immutables:                verified.Immutables,
+		ConstructorArgs:           verified.ConstructorArgs,
+		DecodedConstructorArgs:    decodedConstructorArgs(contract.Abi, verified.ConstructorArgs),
+		UnverifiedConstructorArgs: v.unverifiedConstructorArgs(verified),
+		Libraries:                 verified.Libraries,
+		MatchedSettings:           matchedSettings,
+		Proxy:                     proxy,
+		CreationMatch:             verified.Creation,
+		CreationTx:                v.CreationTx,
+	}, nil
+}
+
+// unverifiedConstructorArgs are the arguments the user supplied when none were found in the creation code,
+// nothing checked them so they are only echoed back, never decoded or stored
+func (v *VerificationRequest) unverifiedConstructorArgs(verified *Match) string {
+	if verified.ConstructorArgs != "" || v.ConstructorArgs == "" {
+		return ""
+	}
+	return util.AddHex(v.ConstructorArgs)
 }
 
 func respondError(w http.ResponseWriter, err error) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(VerificationResponse{VerifiedStatus: mismatch, Message: err.Error()})
+	_ = json.NewEncoder(w).Encode(errorResponse(err))
+}
+
+func errorResponse(err error) *VerificationResponse {
+	resp := &VerificationResponse{VerifiedStatus: mismatch, Message: err.Error()}
+	var mismatchErr *MismatchError
+	if errors.As(err, &mismatchErr) {
+		resp.Diagnostics = mismatchErr.Diagnostics
+		resp.Proxy = mismatchErr.Proxy
+	}
+	return resp
 }
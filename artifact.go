@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"verify-golang/util"
+)
+
+// Hardhat artifacts/build-info/*.json and Foundry out/**/*.json support, both carry the exact
+// compiler input and long version so users do not have to guess the settings
+
+var (
+	ErrUnknownArtifact   = errors.New("unknown artifact format, expected a hardhat build-info or foundry artifact")
+	ErrDuplicateFileName = errors.New("several uploaded files have the same name")
+)
+
+type buildInfo struct {
+	Format          string          `json:"_format"`
+	SolcVersion     string          `json:"solcVersion"`
+	SolcLongVersion string          `json:"solcLongVersion"`
+	Input           json.RawMessage `json:"input"`
+	Output          *struct {
+		Contracts map[string]map[string]struct {
+			Evm struct {
+				Bytecode struct {
+					Object string `json:"object"`
+				} `json:"bytecode"`
+			} `json:"evm"`
+		} `json:"contracts"`
+	} `json:"output"`
+}
+
+type foundryArtifact struct {
+	Metadata    json.RawMessage `json:"metadata"`
+	RawMetadata string          `json:"rawMetadata"`
+}
+
+// parseArtifact builds a verification request without address and chain from an artifact file,
+// sources maps source paths to contents for formats that only reference sources by hash
+func parseArtifact(data []byte, contractName string, sources map[string]string) (*VerificationRequest, error) {
+	var info buildInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, ErrUnknownArtifact
+	}
+	if len(info.Input) > 0 && (info.SolcLongVersion != "" || info.SolcVersion != "") {
+		return info.toVerificationRequest(contractName)
+	}
+
+	var artifact foundryArtifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, ErrUnknownArtifact
+	}
+	return artifact.toVerificationRequest(contractName, sources)
+}
+
+func (b *buildInfo) toVerificationRequest(contractName string) (*VerificationRequest, error) {
+	version := b.SolcLongVersion
+	if version == "" {
+		version = b.SolcVersion
+	}
+	if contractName == "" {
+		var err error
+		if contractName, err = b.pickContract(); err != nil {
+			return nil, err
+		}
+	}
+	return &VerificationRequest{
+		StandardJson:    string(b.Input),
+		ContractName:    contractName,
+		CompilerVersion: version,
+	}, nil
+}
+
+// pickContract selects the only deployable contract of the build, anything else needs an explicit name
+func (b *buildInfo) pickContract() (string, error) {
+	if b.Output == nil {
+		return "", errors.New("contractName is required, build-info has no compiler output")
+	}
+	var candidates []string
+	for sourcePath, contracts := range b.Output.Contracts {
+		for name, contract := range contracts {
+			if util.TrimHex(contract.Evm.Bytecode.Object) != "" {
+				candidates = append(candidates, sourcePath+":"+name)
+			}
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	sort.Strings(candidates)
+	return "", fmt.Errorf("contractName is required, candidates: %s", strings.Join(candidates, ", "))
+}
+
+// metadata parses rawMetadata, the metadata as solc emitted it, and falls back to the metadata object
+func (a *foundryArtifact) metadata() (*SolcMetadata, error) {
+	raw := []byte(a.RawMetadata)
+	if len(raw) == 0 {
+		raw = a.Metadata
+	}
+	var metadata SolcMetadata
+	if len(raw) == 0 || json.Unmarshal(raw, &metadata) != nil || metadata.Compiler == nil {
+		return nil, ErrUnknownArtifact
+	}
+	return &metadata, nil
+}
+
+func (a *foundryArtifact) toVerificationRequest(contractName string, sources map[string]string) (*VerificationRequest, error) {
+	metadata, err := a.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for sourcePath, source := range metadata.Sources {
+		if source.Content != "" {
+			continue
+		}
+		content, ok := findSourceContent(sources, sourcePath, source)
+		if !ok {
+			missing = append(missing, sourcePath)
+			continue
+		}
+		source.Content = content
+		metadata.Sources[sourcePath] = source
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing source files: %s", strings.Join(missing, ", "))
+	}
+	if contractName != "" {
+		sourcePath, name := splitContractName(contractName)
+		if sourcePath == "" {
+			return nil, InvalidContractName
+		}
+		metadata.Settings.CompilationTarget = map[string]string{sourcePath: name}
+	}
+
+	input, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationRequest{Metadata: string(input), CompilerVersion: (*metadata.Compiler)["version"]}, nil
+}
+
+// findSourceContent matches a metadata source against uploaded files, by declared keccak256 first
+// and then by path, uploads often only keep the base name of the file. A base name shared by several
+// files is ambiguous and matches none of them
+func findSourceContent(files map[string]string, sourcePath string, source SolcSources) (string, bool) {
+	if source.Keccak256 != "" {
+		for _, content := range files {
+			if strings.EqualFold(util.Keccak256Hex([]byte(content)), util.AddHex(source.Keccak256)) {
+				return content, true
+			}
+		}
+	}
+	if content, ok := files[sourcePath]; ok {
+		return content, true
+	}
+	var matches []string
+	for name, content := range files {
+		if strings.HasSuffix(sourcePath, "/"+name) || path.Base(name) == path.Base(sourcePath) {
+			matches = append(matches, content)
+		}
+	}
+	if len(matches) != 1 {
+		return "", false
+	}
+	return matches[0], true
+}
+
+// POST /verify/artifact, multipart form with address, chain, optional contractName,
+// the artifact file and, for foundry artifacts, the source files
+func artifactVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(sourcifyMaxUploadSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chain, err := strconv.ParseInt(r.FormValue("chain"), 10, 64)
+	if err != nil {
+		respondError(w, fmt.Errorf("invalid chain %s", r.FormValue("chain")))
+		return
+	}
+	artifacts := r.MultipartForm.File["artifact"]
+	if len(artifacts) != 1 {
+		respondError(w, errors.New("exactly one artifact file is required"))
+		return
+	}
+	data, err := readMultipartFile(artifacts[0])
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	sources := make(map[string]string)
+	if err = readMultipartFiles(r.MultipartForm.File["sources"], sources); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	req, err := parseArtifact(data, r.FormValue("contractName"), sources)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	req.Address = r.FormValue("address")
+	req.Chain = chain
+	if err = req.validate(); err != nil {
+		respondError(w, err)
+		return
+	}
+	resp, err := req.verify(r.Context(), nil)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func readMultipartFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// readMultipartFiles adds uploaded files to files by name. Uploads only keep the base name, two files sharing
+// one cannot be told apart and are refused
+func readMultipartFiles(headers []*multipart.FileHeader, files map[string]string) error {
+	for _, header := range headers {
+		if _, ok := files[header.Filename]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateFileName, header.Filename)
+		}
+		content, err := readMultipartFile(header)
+		if err != nil {
+			return err
+		}
+		files[header.Filename] = string(content)
+	}
+	return nil
+}
+
+// verifyArtifactCommand implements `verify-artifact -chain 46 -address 0x.. [-contract path:Name] [-root .] artifact.json`,
+// foundry sources are read relative to root
+func verifyArtifactCommand(args []string) error {
+	flags := flag.NewFlagSet("verify-artifact", flag.ExitOnError)
+	chain := flags.Int64("chain", 0, "chain id")
+	address := flags.String("address", "", "contract address")
+	contractName := flags.String("contract", "", `contract to verify, "path/File.sol:Name"`)
+	root := flags.String("root", ".", "project root used to read foundry sources")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: verify-artifact -chain <chain> -address <address> [-contract path:Name] [-root dir] <artifact.json>")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	sources, err := readArtifactSources(data, *root)
+	if err != nil {
+		return err
+	}
+	req, err := parseArtifact(data, *contractName, sources)
+	if err != nil {
+		return err
+	}
+	req.Address = *address
+	req.Chain = *chain
+	if err = req.validate(); err != nil {
+		return err
+	}
+	resp, verifyErr := req.verify(context.Background(), func(stage string) {
+		util.Logger().Info(fmt.Sprintf("verification %s", stage))
+	})
+	if verifyErr != nil {
+		resp = errorResponse(verifyErr)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(resp); err != nil {
+		return err
+	}
+	// the exit status tells CI whether the contract verified
+	if verifyErr != nil {
+		return fmt.Errorf("verification failed: %w", verifyErr)
+	}
+	if resp.VerifiedStatus == mismatch {
+		return errors.New("verification failed: bytecode mismatch")
+	}
+	return nil
+}
+
+// readArtifactSources loads the sources referenced by a foundry artifact from the project root
+func readArtifactSources(data []byte, root string) (map[string]string, error) {
+	var artifact foundryArtifact
+	if json.Unmarshal(data, &artifact) != nil {
+		return nil, nil
+	}
+	metadata, err := artifact.metadata()
+	if err != nil {
+		// not a foundry artifact, build-info files embed their sources
+		return nil, nil
+	}
+	sources := make(map[string]string, len(metadata.Sources))
+	for sourcePath, source := range metadata.Sources {
+		if source.Content != "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(sourcePath)))
+		if err != nil {
+			return nil, fmt.Errorf("read source %s: %v", sourcePath, err)
+		}
+		sources[sourcePath] = string(content)
+	}
+	return sources, nil
+}
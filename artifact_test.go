@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"verify-golang/util"
+)
+
+const testBuildInfo = `{
+  "_format": "hh-sol-build-info-1",
+  "solcVersion": "0.8.9",
+  "solcLongVersion": "0.8.9+commit.e5eed63a",
+  "input": {
+    "language": "Solidity",
+    "sources": {
+      "contracts/new.sol": {"content": "pragma solidity ^0.8.0;\ncontract YourContract {}\ninterface IYour {}"}
+    },
+    "settings": {"optimizer": {"enabled": true, "runs": 200}}
+  },
+  "output": {
+    "contracts": {
+      "contracts/new.sol": {
+        "YourContract": {"evm": {"bytecode": {"object": "6080604052"}}},
+        "IYour": {"evm": {"bytecode": {"object": ""}}}
+      }
+    }
+  }
+}`
+
+const testFoundryArtifact = `{
+  "abi": [],
+  "bytecode": {"object": "0x6080604052"},
+  "metadata": {
+    "compiler": {"version": "0.8.9+commit.e5eed63a"},
+    "language": "Solidity",
+    "settings": {
+      "compilationTarget": {"src/Counter.sol": "Counter"},
+      "optimizer": {"enabled": true, "runs": 200}
+    },
+    "sources": {
+      "src/Counter.sol": {"keccak256": "0x58d59bf0071734eb6dd1e3e1991a896685b14795a31553215c4ca784b1be41ee", "urls": []}
+    },
+    "version": 1
+  }
+}`
+
+func Test_parseBuildInfo(t *testing.T) {
+	req, err := parseArtifact([]byte(testBuildInfo), "", nil)
+	if err != nil {
+		t.Fatalf("parseArtifact failed: %v", err)
+	}
+	if req.ContractName != "contracts/new.sol:YourContract" || req.CompilerVersion != "0.8.9+commit.e5eed63a" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if _, err = req.VerifyMetadata(); err != nil {
+		t.Errorf("VerifyMetadata failed: %v", err)
+	}
+
+	multiple := strings.Replace(testBuildInfo, `"object": ""`, `"object": "00"`, 1)
+	if _, err = parseArtifact([]byte(multiple), "", nil); err == nil || !strings.Contains(err.Error(), "candidates") {
+		t.Errorf("expected candidates error, got %v", err)
+	}
+	if req, err = parseArtifact([]byte(multiple), "contracts/new.sol:IYour", nil); err != nil || req.ContractName != "contracts/new.sol:IYour" {
+		t.Errorf("explicit contract name not used: %v", err)
+	}
+}
+
+func Test_parseFoundryArtifact(t *testing.T) {
+	if _, err := parseArtifact([]byte(testFoundryArtifact), "", nil); err == nil {
+		t.Errorf("expected missing source error")
+	}
+
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "src"), 0755)
+	if err := os.WriteFile(filepath.Join(root, "src", "Counter.sol"), []byte("contract Counter {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := readArtifactSources([]byte(testFoundryArtifact), root)
+	if err != nil {
+		t.Fatalf("readArtifactSources failed: %v", err)
+	}
+	req, err := parseArtifact([]byte(testFoundryArtifact), "", sources)
+	if err != nil {
+		t.Fatalf("parseArtifact failed: %v", err)
+	}
+	if req.CompilerVersion != "0.8.9+commit.e5eed63a" {
+		t.Errorf("unexpected compiler version %s", req.CompilerVersion)
+	}
+	metadata, err := req.VerifyMetadata()
+	if err != nil {
+		t.Fatalf("VerifyMetadata failed: %v", err)
+	}
+	target, name := metadata.(*SolcMetadata).PickComplicationTarget()
+	if target != "src/Counter.sol" || name != "Counter" {
+		t.Errorf("unexpected compilation target %s:%s", target, name)
+	}
+
+	if _, err = parseArtifact([]byte(`{"abi": []}`), "", nil); err != ErrUnknownArtifact {
+		t.Errorf("expected ErrUnknownArtifact, got %v", err)
+	}
+}
+
+func Test_findSourceContent(t *testing.T) {
+	source := SolcSources{}
+	files := map[string]string{"token/ERC20.sol": "token", "Counter.sol": "counter"}
+	if content, ok := findSourceContent(files, "src/Counter.sol", source); !ok || content != "counter" {
+		t.Errorf("base name not matched: %s", content)
+	}
+	if content, ok := findSourceContent(files, "lib/ERC20.sol", source); !ok || content != "token" {
+		t.Errorf("unique base name not matched: %s", content)
+	}
+	// two uploads with the same base name never resolve to either of them
+	files["mocks/ERC20.sol"] = "mock"
+	for i := 0; i < 10; i++ {
+		if content, ok := findSourceContent(files, "lib/ERC20.sol", source); ok {
+			t.Fatalf("ambiguous base name matched %s", content)
+		}
+	}
+	if content, ok := findSourceContent(files, "mocks/ERC20.sol", source); !ok || content != "mock" {
+		t.Errorf("exact path not matched: %s", content)
+	}
+	source.Keccak256 = util.Keccak256Hex([]byte("mock"))
+	if content, ok := findSourceContent(files, "lib/ERC20.sol", source); !ok || content != "mock" {
+		t.Errorf("keccak256 not matched: %s", content)
+	}
+}
+
+func Test_verifyArtifactCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "build-info.json")
+	if err := os.WriteFile(file, []byte(testBuildInfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a failed verification fails the command, so that CI can gate on it
+	err := verifyArtifactCommand([]string{"-chain", "990099", "-address", "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f", file})
+	if err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Errorf("expected a failed verification, got %v", err)
+	}
+}
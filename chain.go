@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"sync"
 )
@@ -9,16 +11,30 @@ import (
 var (
 	chainGroup map[int64]ChainInfo
 	regOnce    sync.Once
+
+	ErrCrossCheckEndpoints = errors.New("crossCheck needs at least two rpc endpoints")
 )
 
 type ChainInfo struct {
 	Rpc                  []string `json:"rpc"`
 	ContractFetchAddress string   `json:"contractFetchAddress"`
+	// CrossCheck compares eth_getCode of two rpc endpoints before trusting it
+	CrossCheck bool `json:"crossCheck,omitempty"`
+	// CreationSource is subscan or rpc, subscan when contractFetchAddress is set
+	CreationSource string `json:"creationSource,omitempty"`
 
 	// Deprecated, use metadata input instead
 	// Revive bool `json:"revive"`
 }
 
+// validate rejects settings that would fail every request of the chain
+func (c ChainInfo) validate() error {
+	if c.CrossCheck && len(c.Rpc) < 2 {
+		return ErrCrossCheckEndpoints
+	}
+	return nil
+}
+
 // Read chains.json to get all supported chain information
 // Write to a local variable, only write once
 func fetchChainInfo() map[int64]ChainInfo {
@@ -33,6 +49,11 @@ func fetchChainInfo() map[int64]ChainInfo {
 		if err := json.NewDecoder(file).Decode(&chains); err != nil {
 			panic(err)
 		}
+		for chainId, chain := range chains {
+			if err := chain.validate(); err != nil {
+				panic(fmt.Errorf("chain %d: %w", chainId, err))
+			}
+		}
 		chainGroup = chains
 	})
 	return chainGroup
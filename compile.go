@@ -9,10 +9,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"verify-golang/util"
 )
 
 type IMetadata interface {
-	recompileContract(_ context.Context, version string) (*SolcOutput, error)
+	recompileContract(ctx context.Context, version string) (*SolcOutput, error)
 }
 
 type SolcMetadata struct {
@@ -32,7 +34,15 @@ func (s *SolcMetadata) format() {
 	s.Compiler = nil
 	s.Version = nil
 	s.Settings.CompilationTarget = nil
-	s.Settings.OutputSelection = map[string]map[string]interface{}{"*": {"*": []string{"abi", "evm.bytecode", "evm.deployedBytecode"}}}
+	delete(s.Settings.Extra, "compilationTarget")
+	s.Settings.OutputSelection = map[string]map[string]interface{}{"*": {"*": []string{
+		"abi", "evm.bytecode", "evm.bytecode.linkReferences", "evm.deployedBytecode",
+		"evm.deployedBytecode.linkReferences", "evm.deployedBytecode.immutableReferences", "metadata",
+	}}}
+	// metadata lists libraries as "file:Lib", solc only accepts them nested by file
+	if len(s.Settings.Libraries) > 0 {
+		s.Settings.Libraries = nestedLibraries(libraryAddresses(s.Settings.Libraries))
+	}
 }
 
 func (s *SolcMetadata) PickComplicationTarget() (string, string) {
@@ -50,20 +60,6 @@ func (s *SolcMetadata) String() string {
 
 type SourcesCode map[string]SolcSources
 
-type SolcMetadataSetting struct {
-	Remappings []string `json:"remappings,omitempty"`
-	Optimizer  struct {
-		Enabled bool `json:"enabled"`
-		Runs    int  `json:"runs"`
-	} `json:"optimizer"`
-	EvmVersion        string                 `json:"evmVersion,omitempty"`
-	EOFVersion        *int                   `json:"eofVersion,omitempty"`
-	Libraries         map[string]interface{} `json:"libraries,omitempty"`
-	Metadata          map[string]interface{} `json:"metadata,omitempty"`
-	CompilationTarget map[string]string      `json:"compilationTarget,omitempty"`
-	OutputSelection   interface{}            `json:"outputSelection,omitempty"`
-}
-
 type SolcMetadataOutput struct {
 	Abi                    []interface{} `json:"abi"`
 	Devdoc                 interface{}   `json:"devdoc"`
@@ -72,8 +68,9 @@ type SolcMetadataOutput struct {
 }
 
 type SolcSources struct {
-	Keccak256 string `json:"keccak256"`
-	Content   string `json:"content"`
+	Keccak256 string   `json:"keccak256"`
+	Content   string   `json:"content"`
+	Urls      []string `json:"urls,omitempty"`
 }
 
 type SolcOutput struct {
@@ -92,33 +89,60 @@ type SolcOutput struct {
 		Type string `json:"type"`
 	} `json:"errors"`
 	ReviveVersion string `json:"revive_version,omitempty"` // pvm revive version
+	// Language is Vyper for vyper output, empty for solc
+	Language      string `json:"-"`
 	ContractName  string
 	CompileTarget string
+	// Libraries are the addresses given in settings.libraries, "file:Lib" -> address
+	Libraries map[string]string `json:"-"`
+}
+
+// candidates are the compiled contracts to compare with the chain, only the requested one when the input names
+// its compilation target so that a match is never recorded under another contract
+func (o *SolcOutput) candidates() map[string]map[string]SolcContract {
+	if o.CompileTarget == "" || o.ContractName == "" {
+		return o.Contracts
+	}
+	contract, ok := o.Contracts[o.CompileTarget][o.ContractName]
+	if !ok {
+		return nil
+	}
+	return map[string]map[string]SolcContract{o.CompileTarget: {o.ContractName: contract}}
 }
 
 type SolcContract struct {
 	Abi []any `json:"abi"`
 	Evm struct {
 		Bytecode struct {
-			Object string `json:"object"`
+			Object         string         `json:"object"`
+			LinkReferences LinkReferences `json:"linkReferences,omitempty"`
 		} `json:"bytecode"`
 		DeployedBytecode struct {
-			Object string `json:"object"`
+			Object         string         `json:"object"`
+			LinkReferences LinkReferences `json:"linkReferences,omitempty"`
+			// ImmutableReferences maps the AST id of each immutable to the byte ranges it occupies
+			ImmutableReferences map[string][]BytecodeRange `json:"immutableReferences,omitempty"`
 		}
 	} `json:"evm"`
 	Metadata any `json:"metadata,omitempty"`
 }
 
-func (s *SolcMetadata) recompileContract(_ context.Context, version string) (*SolcOutput, error) {
+type BytecodeRange struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+func (s *SolcMetadata) recompileContract(ctx context.Context, version string) (*SolcOutput, error) {
 	solcPath := filepath.Join(SolcManagerInstance.cacheDir, version)
 
-	cmd := exec.Command(solcPath, "--standard-json")
+	cmd := exec.CommandContext(ctx, solcPath, "--standard-json")
 
 	var stdoutBuf, stderrBuf bytes.Buffer
 	cmd.Stdout = &stdoutBuf
 	cmd.Stderr = &stderrBuf
 	var result SolcOutput
 	result.CompileTarget, result.ContractName = s.PickComplicationTarget()
+	result.Libraries = libraryAddresses(s.Settings.Libraries)
 	stdinPipe, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("create pipe fail: %v", err)
@@ -143,8 +167,10 @@ func (s *SolcMetadata) recompileContract(_ context.Context, version string) (*So
 	return &result, nil
 }
 
+// BytecodeWithoutMetadata strips the CBOR metadata and its length from the end of legacy code. EOF
+// containers keep their metadata inside the data section and are returned unchanged, see evm.CompareEOF
 func BytecodeWithoutMetadata(code string) string {
-	if len(code) < 6 {
+	if len(code) < 6 || strings.HasPrefix(util.TrimHex(code), "ef00") {
 		return code
 	}
 	numericResult, err := strconv.ParseInt(code[len(code)-4:], 16, 64)
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Compiler binaries are fetched once per cache path, callers asking for a version being downloaded wait
+// for that download instead of starting their own. Downloads write to a temp file renamed into place, so
+// a cached binary is always complete.
+
+const (
+	downloadRunning = "downloading"
+	downloadReady   = "ready"
+	downloadFailed  = "failed"
+)
+
+// CompilerDownload is the state of the latest fetch of a compiler version
+type CompilerDownload struct {
+	Compiler string `json:"compiler"`
+	Version  string `json:"version"`
+	Status   string `json:"status"`
+	// ReceivedBytes and TotalBytes report a download in progress, TotalBytes is 0 when the size is unknown
+	ReceivedBytes int64      `json:"received_bytes"`
+	TotalBytes    int64      `json:"total_bytes,omitempty"`
+	Waiters       int        `json:"waiters"`
+	Error         string     `json:"error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+type compilerFetch struct {
+	done     chan struct{}
+	err      error
+	received atomic.Int64
+	total    atomic.Int64
+
+	mu    sync.Mutex
+	state CompilerDownload
+}
+
+// Write counts the bytes received, the fetch is the progress writer of its download
+func (f *compilerFetch) Write(p []byte) (int, error) {
+	f.received.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// expect records the size of the download when known
+func (f *compilerFetch) expect(size int64) {
+	if size > 0 {
+		f.total.Store(size)
+	}
+}
+
+func (f *compilerFetch) snapshot() CompilerDownload {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.state
+	state.ReceivedBytes = f.received.Load()
+	state.TotalBytes = f.total.Load()
+	return state
+}
+
+type compilerFetches struct {
+	mu sync.Mutex
+	// fetches holds the latest fetch of every cache path, running or finished
+	fetches map[string]*compilerFetch
+}
+
+var compilerDownloads = &compilerFetches{fetches: map[string]*compilerFetch{}}
+
+// do runs fetch for path unless it is already running, in which case it waits for that run and
+// returns its error
+func (c *compilerFetches) do(compiler, version, path string, fetch func(f *compilerFetch) error) error {
+	c.mu.Lock()
+	if running, ok := c.fetches[path]; ok && running.snapshot().Status == downloadRunning {
+		running.mu.Lock()
+		running.state.Waiters++
+		running.mu.Unlock()
+		c.mu.Unlock()
+		<-running.done
+		return running.err
+	}
+	f := &compilerFetch{done: make(chan struct{}), state: CompilerDownload{
+		Compiler: compiler, Version: version, Status: downloadRunning, StartedAt: time.Now().UTC(),
+	}}
+	c.fetches[path] = f
+	c.mu.Unlock()
+
+	err := fetch(f)
+	finished := time.Now().UTC()
+	f.mu.Lock()
+	f.err = err
+	f.state.FinishedAt = &finished
+	f.state.Status = downloadReady
+	if err != nil {
+		f.state.Status = downloadFailed
+		f.state.Error = err.Error()
+	}
+	f.mu.Unlock()
+	close(f.done)
+	return err
+}
+
+// List returns the state of every compiler fetched since start, by compiler then version
+func (c *compilerFetches) List() []CompilerDownload {
+	c.mu.Lock()
+	states := make([]CompilerDownload, 0, len(c.fetches))
+	for _, f := range c.fetches {
+		states = append(states, f.snapshot())
+	}
+	c.mu.Unlock()
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Compiler != states[j].Compiler {
+			return states[i].Compiler < states[j].Compiler
+		}
+		return states[i].Version < states[j].Version
+	})
+	return states
+}
+
+// GET /compilers
+func compilersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(compilerDownloads.List())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"verify-golang/util"
+)
+
+// blockingSolcRepo serves testSolcVersion, sending the first half of the binary and then waiting for release
+func blockingSolcRepo(t *testing.T, binary string, status int, release chan struct{}, requests *atomic.Int32) *SolcManager {
+	t.Helper()
+	path := "solc-" + solcPlatform() + "-" + testSolcVersion
+	sha := sha256.Sum256([]byte(binary))
+	list := solcList{
+		Builds:   []solcBuild{{Path: path, Sha256: "0x" + hex.EncodeToString(sha[:]), Keccak256: util.Keccak256Hex([]byte(binary))}},
+		Releases: map[string]string{"0.8.19": path},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list.json":
+			_ = json.NewEncoder(w).Encode(list)
+		case "/" + path:
+			requests.Add(1)
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			w.Header().Set("Content-Length", "64")
+			_, _ = w.Write([]byte(binary[:32]))
+			w.(http.Flusher).Flush()
+			<-release
+			_, _ = w.Write([]byte(binary[32:]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &SolcManager{cacheDir: t.TempDir(), listURL: server.URL + "/list.json", repoURL: server.URL + "/"}
+}
+
+func useCompilerDownloads(t *testing.T) {
+	previous := compilerDownloads
+	compilerDownloads = &compilerFetches{fetches: map[string]*compilerFetch{}}
+	t.Cleanup(func() { compilerDownloads = previous })
+}
+
+// waitDownload polls the download list until check accepts it
+func waitDownload(t *testing.T, check func(CompilerDownload) bool) CompilerDownload {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if states := compilerDownloads.List(); len(states) == 1 && check(states[0]) {
+			return states[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("unexpected downloads %+v", compilerDownloads.List())
+	return CompilerDownload{}
+}
+
+func TestSolcManagerSharesDownloads(t *testing.T) {
+	useCompilerDownloads(t)
+	binary := "#!/bin/sh\n# " + strings.Repeat("x", 52)
+	release := make(chan struct{})
+	var requests atomic.Int32
+	sm := blockingSolcRepo(t, binary, http.StatusOK, release, &requests)
+
+	const callers = 4
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = sm.EnsureVersion(testSolcVersion)
+		}(i)
+	}
+
+	state := waitDownload(t, func(d CompilerDownload) bool {
+		return d.Waiters == callers-1 && d.ReceivedBytes == 32
+	})
+	if state.Compiler != "solc" || state.Version != testSolcVersion || state.Status != downloadRunning || state.TotalBytes != 64 {
+		t.Errorf("unexpected state %+v", state)
+	}
+	// nothing is visible in the cache before the download completes
+	if _, err := os.Stat(filepath.Join(sm.cacheDir, testSolcVersion)); !os.IsNotExist(err) {
+		t.Errorf("partial binary visible: %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("EnsureVersion failed: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("binary requested %d times", requests.Load())
+	}
+	if content, _ := os.ReadFile(filepath.Join(sm.cacheDir, testSolcVersion)); string(content) != binary {
+		t.Errorf("unexpected binary %q", content)
+	}
+
+	recorder := httptest.NewRecorder()
+	compilersHandler(recorder, httptest.NewRequest(http.MethodGet, "/compilers", nil))
+	var states []CompilerDownload
+	if err := json.Unmarshal(recorder.Body.Bytes(), &states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Status != downloadReady || states[0].ReceivedBytes != 64 || states[0].FinishedAt == nil {
+		t.Errorf("unexpected states %+v", states)
+	}
+}
+
+func TestSolcManagerFailedDownload(t *testing.T) {
+	useCompilerDownloads(t)
+	var requests atomic.Int32
+	sm := blockingSolcRepo(t, "", http.StatusInternalServerError, nil, &requests)
+
+	if err := sm.EnsureVersion(testSolcVersion); err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	state := waitDownload(t, func(d CompilerDownload) bool { return d.Status == downloadFailed })
+	if state.Error == "" {
+		t.Errorf("failure not recorded %+v", state)
+	}
+	// a failed download is attempted again
+	if err := sm.EnsureVersion(testSolcVersion); err == nil || requests.Load() != 2 {
+		t.Errorf("expected a second failed request, got %d: %v", requests.Load(), err)
+	}
+	entries, _ := os.ReadDir(sm.cacheDir)
+	if len(entries) != 0 {
+		t.Errorf("files left in the cache: %v", entries)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"verify-golang/util"
+)
+
+// Creation code sources, selected per chain with creationSource in chains.json. Subscan is the default
+// when contractFetchAddress is set, rpc reads the creation transaction from the chain itself
+const (
+	creationSourceSubscan = "subscan"
+	creationSourceRpc     = "rpc"
+)
+
+var (
+	ErrInvalidCreationTx  = errors.New("invalid creationTx, expected a 32 byte transaction hash")
+	ErrCreationTxNotFound = errors.New("creation transaction not found, set creationTx")
+
+	creationTxRegex = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+)
+
+func (c ChainInfo) creationSource() string {
+	if c.CreationSource != "" {
+		return c.CreationSource
+	}
+	if c.ContractFetchAddress != "" {
+		return creationSourceSubscan
+	}
+	return creationSourceRpc
+}
+
+type rpcTransaction struct {
+	Hash  string  `json:"hash"`
+	To    *string `json:"to"`
+	Input string  `json:"input"`
+}
+
+type rpcReceipt struct {
+	Status          string  `json:"status"`
+	ContractAddress *string `json:"contractAddress"`
+}
+
+// callFrame is a call of the callTracer, CREATE and CREATE2 frames carry the init code as input
+type callFrame struct {
+	Type  string      `json:"type"`
+	To    string      `json:"to"`
+	Input string      `json:"input"`
+	Error string      `json:"error,omitempty"`
+	Calls []callFrame `json:"calls,omitempty"`
+}
+
+// findCreateFrame returns the successful CREATE or CREATE2 frame that deployed address
+func findCreateFrame(frame *callFrame, address string) *callFrame {
+	if frame.Error != "" {
+		return nil
+	}
+	if (frame.Type == "CREATE" || frame.Type == "CREATE2") && strings.EqualFold(frame.To, address) {
+		return frame
+	}
+	for i := range frame.Calls {
+		if found := findCreateFrame(&frame.Calls[i], address); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+type creationInputCache struct {
+	once sync.Once
+	code string
+	err  error
+}
+
+// cachedCreationInput is fetchCreationInput done once per verification, each search candidate compares with
+// it and a lookup on an rpc source may scan the chain. Requests compared outside verify fetch every time
+func (v *VerificationRequest) cachedCreationInput(ctx context.Context) (string, error) {
+	if v.creationInput == nil {
+		return v.fetchCreationInput(ctx)
+	}
+	v.creationInput.once.Do(func() {
+		v.creationInput.code, v.creationInput.err = v.fetchCreationInput(ctx)
+	})
+	return v.creationInput.code, v.creationInput.err
+}
+
+// fetchCreationInput returns the creation code with its constructor arguments. A creationTx supplied by
+// the user is read from the chain, otherwise the chain creation source is used and rpc serves as the
+// fallback of a failing Subscan, the creation transaction is then looked up on the chain
+func (v *VerificationRequest) fetchCreationInput(ctx context.Context) (string, error) {
+	chain, ok := chainGroup[v.Chain]
+	if !ok {
+		return "", fmt.Errorf("network %d not supported", v.Chain)
+	}
+	address := v.codeAddress()
+	if v.CreationTx == "" && chain.creationSource() == creationSourceSubscan {
+		creationCode, err := fetchCreateBytecode(ctx, address, v.Chain)
+		if err == nil || len(chain.Rpc) == 0 {
+			return creationCode, err
+		}
+		util.Logger().Info(fmt.Sprintf("subscan creation code of %s unavailable, read it from the chain", address))
+	}
+
+	client, err := rpcClientOf(v.Chain)
+	if err != nil {
+		return "", err
+	}
+	if v.CreationTx != "" {
+		var tx rpcTransaction
+		if err = client.Call(ctx, "eth_getTransactionByHash", []any{v.CreationTx}, &tx); err != nil {
+			return "", err
+		}
+		if tx.Hash == "" {
+			return "", fmt.Errorf("creation transaction %s not found", v.CreationTx)
+		}
+		return creationInputOf(ctx, client, tx, address)
+	}
+	return v.discoverCreationInput(ctx, client)
+}
+
+// creationInputOf reads the creation code of address from tx, its input for a deployment transaction,
+// the input of the CREATE or CREATE2 call found by debug_traceTransaction for a factory
+func creationInputOf(ctx context.Context, client *RpcClient, tx rpcTransaction, address string) (string, error) {
+	deployed, err := deployedBy(ctx, client, tx, address)
+	if err != nil {
+		return "", err
+	}
+	if deployed {
+		return tx.Input, nil
+	}
+	input, err := tracedCreationInput(ctx, client, tx.Hash, address)
+	if err == nil && input == "" {
+		err = fmt.Errorf("transaction %s did not create %s", tx.Hash, address)
+	}
+	return input, err
+}
+
+// deployedBy tells from its receipt whether the deployment transaction tx created address
+func deployedBy(ctx context.Context, client *RpcClient, tx rpcTransaction, address string) (bool, error) {
+	if tx.To != nil {
+		return false, nil
+	}
+	var receipt rpcReceipt
+	if err := client.Call(ctx, "eth_getTransactionReceipt", []any{tx.Hash}, &receipt); err != nil {
+		return false, err
+	}
+	return receipt.ContractAddress != nil && strings.EqualFold(*receipt.ContractAddress, address) && receipt.Status != "0x0", nil
+}
+
+// tracedCreationInput returns the input of the CREATE or CREATE2 call of the transaction that created
+// address, empty when it created none
+func tracedCreationInput(ctx context.Context, client *RpcClient, hash, address string) (string, error) {
+	var trace callFrame
+	if err := client.Call(ctx, "debug_traceTransaction", []any{hash, map[string]string{"tracer": "callTracer"}}, &trace); err != nil {
+		return "", fmt.Errorf("trace transaction %s: %w", hash, err)
+	}
+	if frame := findCreateFrame(&trace, address); frame != nil {
+		return frame.Input, nil
+	}
+	return "", nil
+}
+
+// searchBlock resolves the block of the request to a number, the creation is searched at or below it
+func (v *VerificationRequest) searchBlock(ctx context.Context, client *RpcClient) (uint64, error) {
+	block, err := v.blockParam()
+	if err != nil {
+		return 0, err
+	}
+	var header struct {
+		Number string `json:"number"`
+	}
+	switch block := block.(type) {
+	case string:
+		if number, ok := strings.CutPrefix(block, "0x"); ok {
+			return strconv.ParseUint(number, 16, 64)
+		}
+		if block == "latest" {
+			err = client.Call(ctx, "eth_blockNumber", nil, &header.Number)
+		} else {
+			err = client.Call(ctx, "eth_getBlockByNumber", []any{block, false}, &header)
+		}
+	case map[string]any:
+		err = client.Call(ctx, "eth_getBlockByHash", []any{block["blockHash"], false}, &header)
+	}
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.ParseUint(util.TrimHex(header.Number), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("block %s not found", v.Block)
+	}
+	return number, nil
+}
+
+// discoverCreationInput binary searches the first block with code at the address, at or below the requested
+// block, which needs an archive node, then looks for the transaction of that block creating it. Deployments
+// are checked by their receipt, other transactions are traced up to VERIFY_CREATION_MAX_TRACES of them
+func (v *VerificationRequest) discoverCreationInput(ctx context.Context, client *RpcClient) (string, error) {
+	address := v.codeAddress()
+	high, err := v.searchBlock(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	hasCode := func(number uint64) (bool, error) {
+		var code string
+		if err := client.Call(ctx, "eth_getCode", []any{address, "0x" + strconv.FormatUint(number, 16)}, &code); err != nil {
+			return false, err
+		}
+		return util.TrimHex(code) != "", nil
+	}
+	// a self-destructed contract has no code left to search for
+	if ok, err := hasCode(high); err != nil || !ok {
+		return "", errors.Join(ErrCreationTxNotFound, err)
+	}
+	low := uint64(0)
+	for low < high {
+		middle := low + (high-low)/2
+		ok, err := hasCode(middle)
+		if err != nil {
+			return "", errors.Join(ErrCreationTxNotFound, err)
+		}
+		if ok {
+			high = middle
+		} else {
+			low = middle + 1
+		}
+	}
+
+	var block struct {
+		Transactions []rpcTransaction `json:"transactions"`
+	}
+	if err = client.Call(ctx, "eth_getBlockByNumber", []any{"0x" + strconv.FormatUint(low, 16), true}, &block); err != nil {
+		return "", err
+	}
+	found := func(tx rpcTransaction, input string) (string, error) {
+		util.Logger().Info(fmt.Sprintf("contract %s was created by transaction %s", address, tx.Hash))
+		v.CreationTx = tx.Hash
+		return input, nil
+	}
+	// deployments first, their receipt tells without tracing
+	for _, tx := range block.Transactions {
+		deployed, err := deployedBy(ctx, client, tx, address)
+		if err != nil {
+			return "", errors.Join(ErrCreationTxNotFound, err)
+		}
+		if deployed {
+			return found(tx, tx.Input)
+		}
+	}
+	maxTraces := util.EnvInt("VERIFY_CREATION_MAX_TRACES", 16)
+	for i, tx := range block.Transactions {
+		if i == maxTraces {
+			return "", fmt.Errorf("%w, block %d has %d transactions and the first %d traced did not create %s", ErrCreationTxNotFound, low, len(block.Transactions), maxTraces, address)
+		}
+		input, err := tracedCreationInput(ctx, client, tx.Hash, address)
+		if err != nil {
+			return "", errors.Join(ErrCreationTxNotFound, err)
+		}
+		if input != "" {
+			return found(tx, input)
+		}
+	}
+	return "", ErrCreationTxNotFound
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	testCreatedAddress = "0x00000000001523057a05d6293c1e5171ee33ee0a"
+	testDeployTx       = "0x1111111111111111111111111111111111111111111111111111111111111111"
+	testFactoryTx      = "0x2222222222222222222222222222222222222222222222222222222222222222"
+	testCallTx         = "0x3333333333333333333333333333333333333333333333333333333333333333"
+	testBlockHash      = "0x4444444444444444444444444444444444444444444444444444444444444444"
+	testCreationBlock  = 42
+)
+
+// creationTestChain serves a chain where testCreatedAddress was deployed at testCreationBlock, by
+// testFactoryTx through a factory, or by testDeployTx directly when direct is set
+func creationTestChain(t *testing.T, direct bool, subscan string) (int64, *atomic.Int32) {
+	t.Helper()
+	var getCodeCalls atomic.Int32
+	factory := "0x00000000000000000000000000000000000000fa"
+	transactions := map[string]map[string]any{
+		testDeployTx:  {"hash": testDeployTx, "to": nil, "input": "0x6080deploy"},
+		testFactoryTx: {"hash": testFactoryTx, "to": factory, "input": "0xfactory"},
+		testCallTx:    {"hash": testCallTx, "to": factory, "input": "0x"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		param := func(i int) string {
+			var value string
+			_ = json.Unmarshal(req.Params[i], &value)
+			return value
+		}
+		var result any
+		switch req.Method {
+		case "eth_blockNumber":
+			result = "0x64"
+		case "eth_getCode":
+			getCodeCalls.Add(1)
+			block, _ := strconv.ParseUint(strings.TrimPrefix(param(1), "0x"), 16, 64)
+			result = "0x"
+			if block >= testCreationBlock {
+				result = "0x6080604052"
+			}
+		case "eth_getBlockByNumber":
+			result = map[string]any{"transactions": []any{}}
+			if param(0) == "0x2a" {
+				deployment := testFactoryTx
+				if direct {
+					deployment = testDeployTx
+				}
+				result = map[string]any{"transactions": []any{transactions[testCallTx], transactions[deployment]}}
+			}
+		case "eth_getBlockByHash":
+			result = map[string]any{"number": "0xa"}
+		case "eth_getTransactionByHash":
+			result = transactions[param(0)]
+		case "eth_getTransactionReceipt":
+			result = map[string]any{"status": "0x1", "contractAddress": testCreatedAddress}
+		case "debug_traceTransaction":
+			result = map[string]any{"type": "CALL", "to": factory, "input": "0x", "calls": []any{}}
+			if param(0) == testFactoryTx {
+				result = map[string]any{"type": "CALL", "to": factory, "input": "0xfactory", "calls": []any{
+					map[string]any{"type": "CREATE2", "to": testCreatedAddress, "input": "0x6080reverted", "error": "execution reverted"},
+					map[string]any{"type": "CALL", "to": factory, "input": "0x", "calls": []any{
+						map[string]any{"type": "CREATE2", "to": testCreatedAddress, "input": "0x6080factory"},
+					}},
+				}}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	return registerTestChain(t, ChainInfo{Rpc: []string{server.URL}, ContractFetchAddress: subscan}), &getCodeCalls
+}
+
+func Test_fetchCreationInputTx(t *testing.T) {
+	chain, _ := creationTestChain(t, false, "")
+	tests := []struct {
+		tx    string
+		input string
+	}{
+		{testDeployTx, "0x6080deploy"},
+		{testFactoryTx, "0x6080factory"},
+	}
+	for _, tt := range tests {
+		req := VerificationRequest{Chain: chain, Address: testCreatedAddress, CreationTx: tt.tx}
+		if input, err := req.fetchCreationInput(context.Background()); err != nil || input != tt.input {
+			t.Errorf("fetchCreationInput(%s) = %s, %v; want %s", tt.tx, input, err, tt.input)
+		}
+	}
+
+	req := VerificationRequest{Chain: chain, Address: testCreatedAddress, CreationTx: testCallTx}
+	if _, err := req.fetchCreationInput(context.Background()); err == nil || !strings.Contains(err.Error(), "did not create") {
+		t.Errorf("expected a transaction that did not create the contract, got %v", err)
+	}
+}
+
+func Test_discoverCreationInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		direct bool
+		input  string
+		tx     string
+	}{
+		{"factory", false, "0x6080factory", testFactoryTx},
+		{"deployment", true, "0x6080deploy", testDeployTx},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, getCodeCalls := creationTestChain(t, tt.direct, "")
+			req := VerificationRequest{Chain: chain, Address: testCreatedAddress}
+			input, err := req.fetchCreationInput(context.Background())
+			if err != nil || input != tt.input || req.CreationTx != tt.tx {
+				t.Errorf("fetchCreationInput() = %s, %s, %v; want %s", input, req.CreationTx, err, tt.input)
+			}
+			// a binary search over the 100 blocks
+			if calls := getCodeCalls.Load(); calls > 10 {
+				t.Errorf("eth_getCode called %d times", calls)
+			}
+		})
+	}
+}
+
+func Test_discoverCreationInputBounds(t *testing.T) {
+	chain, _ := creationTestChain(t, false, "")
+
+	// a block hash is resolved to its number, 10 being before the creation
+	req := VerificationRequest{Chain: chain, Address: testCreatedAddress, Block: json.RawMessage(`"` + testBlockHash + `"`)}
+	if _, err := req.fetchCreationInput(context.Background()); !errors.Is(err, ErrCreationTxNotFound) {
+		t.Errorf("expected ErrCreationTxNotFound, got %v", err)
+	}
+
+	// the factory transaction comes after a call the single allowed trace is spent on
+	t.Setenv("VERIFY_CREATION_MAX_TRACES", "1")
+	req = VerificationRequest{Chain: chain, Address: testCreatedAddress}
+	if _, err := req.fetchCreationInput(context.Background()); !errors.Is(err, ErrCreationTxNotFound) || !strings.Contains(err.Error(), "first 1 traced") {
+		t.Errorf("expected the trace limit, got %v", err)
+	}
+}
+
+func Test_cachedCreationInput(t *testing.T) {
+	chain, getCodeCalls := creationTestChain(t, false, "")
+	req := VerificationRequest{Chain: chain, Address: testCreatedAddress, creationInput: &creationInputCache{}}
+	// search candidates compare in parallel, the chain is scanned once for all of them
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if input, err := req.cachedCreationInput(context.Background()); err != nil || input != "0x6080factory" {
+				t.Errorf("cachedCreationInput() = %s, %v", input, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := getCodeCalls.Load(); calls > 10 {
+		t.Errorf("eth_getCode called %d times", calls)
+	}
+}
+
+func Test_fetchCreationInputSubscanFallback(t *testing.T) {
+	subscan := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 10004, "message": "record not found"})
+	}))
+	defer subscan.Close()
+	chain, _ := creationTestChain(t, false, subscan.URL)
+	req := VerificationRequest{Chain: chain, Address: testCreatedAddress}
+	if input, err := req.fetchCreationInput(context.Background()); err != nil || input != "0x6080factory" {
+		t.Errorf("fetchCreationInput() = %s, %v", input, err)
+	}
+
+	// nothing to search for above the requested block
+	req = VerificationRequest{Chain: chain, Address: testCreatedAddress, Block: json.RawMessage(`10`)}
+	if _, err := req.fetchCreationInput(context.Background()); !errors.Is(err, ErrCreationTxNotFound) {
+		t.Errorf("expected ErrCreationTxNotFound, got %v", err)
+	}
+}
+
+func Test_validateCreationTx(t *testing.T) {
+	req := VerificationRequest{Metadata: "{}", Address: testCreatedAddress, Chain: 46, CreationTx: "0x1234"}
+	if err := req.validate(); err != ErrInvalidCreationTx {
+		t.Errorf("expected ErrInvalidCreationTx, got %v", err)
+	}
+	req.CreationTx = testDeployTx
+	if err := req.validate(); err != nil {
+		t.Errorf("validate failed: %v", err)
+	}
+}
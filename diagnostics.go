@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"verify-golang/evm"
+)
+
+// Where the first difference between the compiled and the on-chain code lies
+const (
+	regionMetadata        = "cbor_metadata"
+	regionImmutables      = "immutables"
+	regionLibrary         = "library"
+	regionConstructorArgs = "constructor_args"
+	regionCode            = "code"
+	regionEOFHeader       = "eof_header"
+	regionEOFData         = "eof_data"
+)
+
+const diagnosticContext = 5
+
+// MismatchDiagnostic explains why one compiled contract does not match the on-chain runtime code
+type MismatchDiagnostic struct {
+	Contract       string `json:"contract"`
+	CompiledLength int    `json:"compiled_length"`
+	OnchainLength  int    `json:"onchain_length"`
+	// FirstDifference is the byte offset of the first difference, -1 when one code is a prefix of the other
+	FirstDifference int    `json:"first_difference"`
+	Region          string `json:"region"`
+	// Section is the differing section of an EOF container, FirstDifference is then relative to it
+	Section         string   `json:"section,omitempty"`
+	CompiledOpcodes []string `json:"compiled_opcodes,omitempty"`
+	OnchainOpcodes  []string `json:"onchain_opcodes,omitempty"`
+	Hints           []string `json:"hints,omitempty"`
+}
+
+// MismatchError is returned when no compiled contract matches, with a diagnostic per candidate
+type MismatchError struct {
+	Diagnostics []MismatchDiagnostic
+	// Searched is the number of compiler settings tried by a search
+	Searched int
+	// Proxy is set when the code compared was a proxy rather than its implementation
+	Proxy *ProxyInfo
+}
+
+func (e *MismatchError) Error() string {
+	message := "bytecode mismatch"
+	if e.Searched > 0 {
+		message = fmt.Sprintf("bytecode mismatch, none of %d searched compiler settings matched", e.Searched)
+	}
+	if e.Proxy != nil && e.Proxy.Implementation != "" && !e.Proxy.Resolved {
+		message += fmt.Sprintf(", the address is a %s proxy of %s, set verifyImplementation to verify the implementation", e.Proxy.Type, e.Proxy.Implementation)
+	}
+	return message
+}
+
+// sortDiagnostics puts the closest candidates first, those differing only late in the code
+func sortDiagnostics(diagnostics []MismatchDiagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].FirstDifference != diagnostics[j].FirstDifference {
+			return diagnostics[i].FirstDifference > diagnostics[j].FirstDifference
+		}
+		return diagnostics[i].Contract < diagnostics[j].Contract
+	})
+}
+
+// firstDifference returns the first differing byte offset of two hex codes, -1 if one is a prefix of the other
+func firstDifference(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i / 2
+		}
+	}
+	return -1
+}
+
+// decodeCode decodes hex code, unlinked library placeholders become zero bytes
+func decodeCode(code string) []byte {
+	raw, err := hex.DecodeString(code)
+	if err != nil {
+		raw, _ = hex.DecodeString(strings.Map(func(r rune) rune {
+			if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return r
+			}
+			return '0'
+		}, code))
+	}
+	return raw
+}
+
+// instructionsAround lists the instructions near the one covering offset
+func instructionsAround(code []byte, offset int) []string {
+	return listAround(evm.Disassemble(code), offset)
+}
+
+func listAround(instructions []evm.Instruction, offset int) []string {
+	index := evm.InstructionAt(instructions, offset)
+	var lines []string
+	for _, ins := range instructions[max(index-diagnosticContext, 0):min(index+diagnosticContext+1, len(instructions))] {
+		lines = append(lines, ins.String())
+	}
+	return lines
+}
+
+func inRanges(offset int, ranges []BytecodeRange) bool {
+	for _, r := range ranges {
+		if offset >= r.Start && offset < r.Start+r.Length {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnoseMismatch compares the linked compiled runtime code, immutables still zeroed, with the on-chain one,
+// both hex without 0x.
+// argsDiffer tells that the creation code matched but not with the constructor arguments the user supplied
+func diagnoseMismatch(name, compiled, chain string, contract SolcContract, argsDiffer bool) MismatchDiagnostic {
+	diagnostic := MismatchDiagnostic{
+		Contract:        name,
+		CompiledLength:  len(compiled) / 2,
+		OnchainLength:   len(chain) / 2,
+		FirstDifference: firstDifference(compiled, chain),
+		Region:          regionCode,
+	}
+	compiledCode, chainCode := decodeCode(compiled), decodeCode(chain)
+	offset := diagnostic.FirstDifference
+	if offset < 0 {
+		offset = min(len(compiledCode), len(chainCode))
+	}
+	diagnostic.CompiledOpcodes = instructionsAround(compiledCode, offset)
+	diagnostic.OnchainOpcodes = instructionsAround(chainCode, offset)
+
+	var immutables, libraries []BytecodeRange
+	for _, ranges := range contract.Evm.DeployedBytecode.ImmutableReferences {
+		immutables = append(immutables, ranges...)
+	}
+	for _, contracts := range contract.Evm.DeployedBytecode.LinkReferences {
+		for _, ranges := range contracts {
+			libraries = append(libraries, ranges...)
+		}
+	}
+
+	switch {
+	case argsDiffer:
+		diagnostic.Region = regionConstructorArgs
+	case BytecodeWithoutMetadata(compiled) == BytecodeWithoutMetadata(chain):
+		diagnostic.Region = regionMetadata
+	case inRanges(offset, immutables):
+		diagnostic.Region = regionImmutables
+	case inRanges(offset, libraries) || strings.Contains(compiled[min(offset*2, len(compiled)):min(offset*2+40, len(compiled))], "_"):
+		diagnostic.Region = regionLibrary
+	}
+	diagnostic.Hints = mismatchHints(diagnostic, compiled, chain)
+	return diagnostic
+}
+
+// mismatchHints guesses the setting that most likely explains a difference
+func mismatchHints(diagnostic MismatchDiagnostic, compiled, chain string) []string {
+	switch diagnostic.Region {
+	case regionMetadata:
+		return []string{"only the metadata hash differs: check source file names and contents, remappings and settings.metadata"}
+	case regionImmutables:
+		return []string{"the code first differs at an immutable, whose on-chain value is copied before comparing: the code after it or the immutable layout differs"}
+	case regionLibrary:
+		return []string{"the difference is a library address, pass the deployed libraries in settings.libraries"}
+	case regionConstructorArgs:
+		return []string{"the creation code matches but its constructor arguments differ from the supplied constructorArgs"}
+	}
+
+	hints := compilerVersionHints(compiled, chain)
+	compiledCode := decodeCode(BytecodeWithoutMetadata(compiled))
+	chainCode := decodeCode(BytecodeWithoutMetadata(chain))
+	if evm.IsEOF(chainCode) {
+		return append(hints, "the on-chain code is an EOF container, compile with settings.eofVersion")
+	}
+	for _, feature := range []struct {
+		op     evm.OpCode
+		evmVer string
+	}{{evm.PUSH0, "shanghai"}, {evm.MCOPY, "cancun"}} {
+		inCompiled, inChain := evm.Uses(compiledCode, feature.op), evm.Uses(chainCode, feature.op)
+		switch {
+		case inChain && !inCompiled:
+			hints = append(hints, fmt.Sprintf("evmVersion: the on-chain code uses %s, compile for %s or later", feature.op, feature.evmVer))
+		case inCompiled && !inChain:
+			hints = append(hints, fmt.Sprintf("evmVersion: the compiled code uses %s but the on-chain code does not, compile for an evmVersion before %s", feature.op, feature.evmVer))
+		}
+	}
+
+	return append(hints, optimizerHint(compiledCode, chainCode))
+}
+
+func compilerVersionHints(compiled, chain string) []string {
+	compiledMetadata, _ := DecodeBytecodeMetadata(compiled)
+	chainMetadata, _ := DecodeBytecodeMetadata(chain)
+	if compiledMetadata != nil && chainMetadata != nil && compiledMetadata.Solc != "" && chainMetadata.Solc != "" && compiledMetadata.Solc != chainMetadata.Solc {
+		return []string{fmt.Sprintf("compiler version: the chain was built with %s, recompiled with %s", chainMetadata.Solc, compiledMetadata.Solc)}
+	}
+	return nil
+}
+
+func optimizerHint(compiledCode, chainCode []byte) string {
+	if len(compiledCode) != len(chainCode) {
+		return fmt.Sprintf("optimizer: the code sizes differ by %d bytes, check optimizer.enabled, optimizer.runs and viaIR", len(chainCode)-len(compiledCode))
+	}
+	return "optimizer: same size but different code, check optimizer.runs and viaIR"
+}
+
+// diagnoseEOFMismatch explains the first section difference of an EOF container, err being
+// the failure to parse one of the containers
+func diagnoseEOFMismatch(name string, compiled, chain []byte, diff *evm.EOFDifference, err error) MismatchDiagnostic {
+	diagnostic := MismatchDiagnostic{
+		Contract:       name,
+		CompiledLength: len(compiled),
+		OnchainLength:  len(chain),
+		Region:         regionEOFHeader,
+	}
+	if err != nil {
+		if !evm.IsEOF(chain) {
+			diagnostic.Hints = []string{"the on-chain code is not an EOF container, compile without settings.eofVersion"}
+		} else {
+			diagnostic.Hints = []string{err.Error()}
+		}
+		return diagnostic
+	}
+
+	diagnostic.Section = diff.Section
+	diagnostic.FirstDifference = diff.Offset
+	diagnostic.Hints = compilerVersionHints(hex.EncodeToString(compiled), hex.EncodeToString(chain))
+	switch {
+	case diff.Compiled != nil:
+		diagnostic.Region = regionCode
+		diagnostic.CompiledOpcodes = listAround(evm.DisassembleEOF(diff.Compiled), diff.Offset)
+		diagnostic.OnchainOpcodes = listAround(evm.DisassembleEOF(diff.Deployed), diff.Offset)
+		diagnostic.Hints = append(diagnostic.Hints, optimizerHint(diff.Compiled, diff.Deployed))
+	case strings.HasSuffix(diff.Section, "data"):
+		diagnostic.Region = regionEOFData
+		diagnostic.Hints = append(diagnostic.Hints, "the data section differs, check constant values and the compiler version")
+	default:
+		diagnostic.Hints = append(diagnostic.Hints, "the container layout differs, check optimizer settings, viaIR and eofVersion")
+	}
+	return diagnostic
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_compareBytecodesDiagnostics(t *testing.T) {
+	// the chain was compiled for shanghai, PUSH0 instead of PUSH1 0x00
+	chain := "0x5f5f5260206000f3" + testIpfsMetadata + "0033"
+	var contract SolcContract
+	contract.Evm.DeployedBytecode.Object = "60006000526020" + "6000f3" + testIpfsMetadata + "0033"
+	var abstract SolcContract
+	output := SolcOutput{Contracts: map[string]map[string]SolcContract{"Token.sol": {"Token": contract, "IToken": abstract}}}
+
+	req := VerificationRequest{Address: "0x00000000001523057a05d6293c1e5171ee33ee0a", Chain: 46}
+	match, err := req.compareBytecodes(context.Background(), chain, &output)
+	if err != nil {
+		t.Fatalf("compareBytecodes failed: %v", err)
+	}
+	if match.Status != mismatch || len(match.Diagnostics) != 1 {
+		t.Fatalf("expected one diagnostic, got %+v", match)
+	}
+	diagnostic := match.Diagnostics[0]
+	if diagnostic.Contract != "Token.sol:Token" || diagnostic.FirstDifference != 0 || diagnostic.Region != regionCode {
+		t.Errorf("unexpected diagnostic %+v", diagnostic)
+	}
+	if diagnostic.OnchainOpcodes[0] != "0000: PUSH0" || diagnostic.CompiledOpcodes[0] != "0000: PUSH1 0x00" {
+		t.Errorf("unexpected opcodes %v %v", diagnostic.OnchainOpcodes, diagnostic.CompiledOpcodes)
+	}
+	if len(diagnostic.Hints) == 0 || !strings.Contains(diagnostic.Hints[0], "PUSH0") {
+		t.Errorf("expected an evmVersion hint, got %v", diagnostic.Hints)
+	}
+
+	// code of the same length needs the creation code, a chain that cannot provide it still gets the diagnostics
+	sameLength := "0x60016000526020" + "6000f3" + testIpfsMetadata + "0033"
+	unknownChain := VerificationRequest{Address: "0x00000000001523057a05d6293c1e5171ee33ee0a", Chain: -1}
+	if match, err := unknownChain.compareBytecodes(context.Background(), sameLength, &output); err != nil || match.Status != mismatch || len(match.Diagnostics) != 1 {
+		t.Errorf("expected a diagnosed mismatch without creation data, got %+v, %v", match, err)
+	} else if hints := match.Diagnostics[0].Hints; !strings.Contains(hints[len(hints)-1], "network -1 not supported") {
+		t.Errorf("creation code failure not reported: %v", hints)
+	}
+
+	// an immutable holding its on-chain value is the first difference of the code as compiled
+	var immutable SolcContract
+	immutable.Evm.DeployedBytecode.Object = "7f" + strings.Repeat("00", 32) + "50" + "6000f3"
+	immutable.Evm.DeployedBytecode.ImmutableReferences = map[string][]BytecodeRange{"3": {{Start: 1, Length: 32}}}
+	immutableOutput := SolcOutput{Contracts: map[string]map[string]SolcContract{"Token.sol": {"Token": immutable}}}
+	immutableChain := "0x7f" + strings.Repeat("11", 32) + "50" + "60016000f3"
+	if match, _ := req.compareBytecodes(context.Background(), immutableChain, &immutableOutput); match.Status != mismatch ||
+		len(match.Diagnostics) != 1 || match.Diagnostics[0].Region != regionImmutables || match.Diagnostics[0].FirstDifference != 1 {
+		t.Errorf("expected an immutables difference, got %+v", match)
+	}
+
+	resp := errorResponse(&MismatchError{Diagnostics: match.Diagnostics})
+	if resp.Message != "bytecode mismatch" || !reflect.DeepEqual(resp.Diagnostics, match.Diagnostics) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func Test_diagnoseMismatchRegions(t *testing.T) {
+	code := "6080604052600080fd"
+	otherMetadata := strings.Replace(testIpfsMetadata, "8c0f", "0000", 1)
+
+	diagnostic := diagnoseMismatch("A.sol:A", code+testIpfsMetadata+"0033", code+otherMetadata+"0033", SolcContract{}, false)
+	if diagnostic.Region != regionMetadata || diagnostic.FirstDifference <= len(code)/2 {
+		t.Errorf("expected a metadata difference, got %+v", diagnostic)
+	}
+
+	var contract SolcContract
+	contract.Evm.DeployedBytecode.ImmutableReferences = map[string][]BytecodeRange{"3": {{Start: 1, Length: 32}}}
+	compiled := "7f" + strings.Repeat("00", 32) + "50"
+	chain := "7f" + strings.Repeat("00", 31) + "01" + "50"
+	if diagnostic = diagnoseMismatch("A.sol:A", compiled, chain, contract, false); diagnostic.Region != regionImmutables {
+		t.Errorf("expected an immutables difference, got %+v", diagnostic)
+	}
+
+	unlinked := "73" + testPlaceholder("contracts/Lib.sol:Lib") + "5af4"
+	if diagnostic = diagnoseMismatch("A.sol:A", unlinked, "73"+strings.Repeat("00", 20), SolcContract{}, false); diagnostic.Region != regionLibrary {
+		t.Errorf("expected a library difference, got %+v", diagnostic)
+	}
+
+	if diagnostic = diagnoseMismatch("A.sol:A", code, code+"00", SolcContract{}, true); diagnostic.Region != regionConstructorArgs {
+		t.Errorf("expected a constructor args difference, got %+v", diagnostic)
+	}
+}
+
+// testEOFContainer builds an EOF container with one non-returning code section
+func testEOFContainer(code, data string, dataSize int) string {
+	return fmt.Sprintf("ef0001"+"010004"+"020001%04x"+"ff%04x00"+"00800000", len(code)/2, dataSize) + code + data
+}
+
+func Test_compareBytecodesEOF(t *testing.T) {
+	code := "5f5f5260206000f3"
+	metadata := testIpfsMetadata + "0033"
+	otherMetadata := strings.Replace(testIpfsMetadata, "8c0f", "0000", 1) + "0033"
+	// a 32 byte immutable is appended to the data at deploy time
+	dataSize := len(metadata)/2 + 32
+	immutable := strings.Repeat("11", 32)
+	var contract SolcContract
+	contract.Evm.DeployedBytecode.Object = testEOFContainer(code, metadata, dataSize)
+	req := VerificationRequest{Address: "0x00000000001523057a05d6293c1e5171ee33ee0a", Chain: 46}
+
+	tests := []struct {
+		name   string
+		chain  string
+		status string
+	}{
+		{"perfect", testEOFContainer(code, metadata+immutable, dataSize), perfect},
+		{"partial", testEOFContainer(code, otherMetadata+immutable, dataSize), partial},
+		{"mismatch", testEOFContainer("5f5f5260206001f3", metadata+immutable, dataSize), mismatch},
+		{"legacy", "0x5f5f5260206000f3" + metadata, mismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := SolcOutput{Contracts: map[string]map[string]SolcContract{"Token.sol": {"Token": contract}}}
+			match, err := req.compareBytecodes(context.Background(), tt.chain, &output)
+			if err != nil {
+				t.Fatalf("compareBytecodes failed: %v", err)
+			}
+			if match.Status != tt.status {
+				t.Fatalf("got status %s, want %s", match.Status, tt.status)
+			}
+			if tt.status != mismatch && output.ContractName != "Token" {
+				t.Errorf("matched contract not recorded")
+			}
+		})
+	}
+
+	output := SolcOutput{Contracts: map[string]map[string]SolcContract{"Token.sol": {"Token": contract}}}
+	match, _ := req.compareBytecodes(context.Background(), tests[2].chain, &output)
+	diagnostic := match.Diagnostics[0]
+	if diagnostic.Section != "code 0" || diagnostic.FirstDifference != 6 || diagnostic.Region != regionCode {
+		t.Errorf("unexpected diagnostic %+v", diagnostic)
+	}
+	if diagnostic.CompiledOpcodes[len(diagnostic.CompiledOpcodes)-2] != "0005: PUSH1 0x00" || diagnostic.OnchainOpcodes[len(diagnostic.OnchainOpcodes)-2] != "0005: PUSH1 0x01" {
+		t.Errorf("unexpected opcodes %v %v", diagnostic.CompiledOpcodes, diagnostic.OnchainOpcodes)
+	}
+
+	match, _ = req.compareBytecodes(context.Background(), tests[3].chain, &output)
+	if diagnostic = match.Diagnostics[0]; diagnostic.Region != regionEOFHeader || !strings.Contains(diagnostic.Hints[0], "not an EOF container") {
+		t.Errorf("unexpected diagnostic %+v", diagnostic)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"verify-golang/evm"
+	"verify-golang/util"
+)
+
+var ErrNotEOFContainer = errors.New("code is not an EOF container")
+
+// disassembleRequest takes either code, such as a recompiled object, or a chain and address to read it from
+type disassembleRequest struct {
+	Code    string `json:"code,omitempty"`
+	Chain   int64  `json:"chain"`
+	Address string `json:"address,omitempty"`
+	// EOFVersion requires an EOF container, EOF code is also recognised by its magic without it
+	EOFVersion *int `json:"eofVersion,omitempty"`
+}
+
+type disassembleResponse struct {
+	Format       string            `json:"format"`
+	Length       int               `json:"length"`
+	Instructions []evm.Instruction `json:"instructions,omitempty"`
+	Data         string            `json:"data,omitempty"`
+	Metadata     *BytecodeMetadata `json:"metadata,omitempty"`
+	EOF          *eofListing       `json:"eof,omitempty"`
+}
+
+type eofListing struct {
+	Version    byte                `json:"version"`
+	Types      []evm.TypeSection   `json:"types"`
+	Code       [][]evm.Instruction `json:"code"`
+	Containers []string            `json:"containers,omitempty"`
+	Data       string              `json:"data,omitempty"`
+	DataSize   int                 `json:"data_size"`
+}
+
+func hexOrEmpty(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(data)
+}
+
+func disassembleCode(code []byte, requireEOF bool) (*disassembleResponse, error) {
+	resp := disassembleResponse{Format: "legacy", Length: len(code)}
+	if evm.IsEOF(code) {
+		container, err := evm.ParseEOF(code)
+		if err != nil {
+			return nil, err
+		}
+		listing := eofListing{Version: container.Version, Types: container.Types, Data: hexOrEmpty(container.Data), DataSize: container.DataSize}
+		for _, section := range container.Code {
+			listing.Code = append(listing.Code, evm.DisassembleEOF(section))
+		}
+		for _, subContainer := range container.Containers {
+			listing.Containers = append(listing.Containers, hexOrEmpty(subContainer))
+		}
+		resp.Metadata, _ = DecodeBytecodeMetadata(hex.EncodeToString(code))
+		resp.Format = "eof"
+		resp.EOF = &listing
+		return &resp, nil
+	}
+	if requireEOF {
+		return nil, ErrNotEOFContainer
+	}
+
+	layout := evm.Split(code)
+	resp.Instructions = evm.Disassemble(layout.Code)
+	resp.Data = hexOrEmpty(layout.Data)
+	if len(layout.Metadata) > 0 {
+		resp.Metadata, _ = DecodeBytecodeMetadata(hex.EncodeToString(layout.Metadata))
+	}
+	return &resp, nil
+}
+
+// POST /disassemble
+func disassembleHandler(w http.ResponseWriter, r *http.Request) {
+	var req disassembleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := req.Code
+	if code == "" {
+		if !util.VerifyEthereumAddress(req.Address) {
+			http.Error(w, "code or a valid address is required", http.StatusBadRequest)
+			return
+		}
+		fetch := VerificationRequest{Chain: req.Chain, Address: req.Address}
+		var err error
+		if code, err = fetch.fetchChainBytecode(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if util.TrimHex(code) == "" {
+			http.Error(w, ErrBytecodeNotFound.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(util.TrimHex(code)))
+	if err != nil {
+		http.Error(w, "invalid code: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := disassembleCode(raw, req.EOFVersion != nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
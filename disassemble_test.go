@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// disassembleListing reads back a disassembleResponse, instructions are listed with their opcode names
+type disassembleListing struct {
+	Format       string `json:"format"`
+	Instructions []struct {
+		Offset int    `json:"offset"`
+		Op     string `json:"op"`
+	} `json:"instructions"`
+	Metadata *BytecodeMetadata `json:"metadata"`
+	EOF      *struct {
+		Code [][]struct {
+			Op string `json:"op"`
+		} `json:"code"`
+		Data string `json:"data"`
+	} `json:"eof"`
+}
+
+func disassembleTestRequest(t *testing.T, body string) (*httptest.ResponseRecorder, *disassembleListing) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	disassembleHandler(rr, httptest.NewRequest("POST", "/disassemble", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		return rr, nil
+	}
+	var resp disassembleListing
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return rr, &resp
+}
+
+func Test_disassembleHandler(t *testing.T) {
+	metadata := "a26469706673582212208c0f254fc3c3b217e54226bdd6f2f788a6b770c6a3c09ea3501cfe671333744564736f6c63430008130033"
+	_, resp := disassembleTestRequest(t, `{"code":"0x6080604052600080fdfe`+metadata+`"}`)
+	if resp == nil || resp.Format != "legacy" || len(resp.Instructions) != 7 || resp.Instructions[6].Op != "INVALID" || resp.Instructions[6].Offset != 9 {
+		t.Fatalf("unexpected legacy listing %+v", resp)
+	}
+	if resp.Metadata == nil || resp.Metadata.Solc != "0.8.19" {
+		t.Errorf("metadata not decoded: %+v", resp.Metadata)
+	}
+
+	eof := "ef0001" + "010004" + "0200010004" + "ff0002" + "00" + "00800000" + "e0000000" + "aabb"
+	_, resp = disassembleTestRequest(t, `{"code":"`+eof+`","eofVersion":1}`)
+	if resp == nil || resp.Format != "eof" || resp.EOF == nil || len(resp.EOF.Code) != 1 || resp.EOF.Code[0][0].Op != "RJUMP" || resp.EOF.Data != "0xaabb" {
+		t.Fatalf("unexpected eof listing %+v", resp)
+	}
+
+	for _, body := range []string{`{"code":"6080604052","eofVersion":1}`, `{"code":"zz"}`, `{"chain":46}`, `{`} {
+		if rr, _ := disassembleTestRequest(t, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Etherscan compatible API, used by hardhat-verify and `forge verify-contract --verifier etherscan`
+// https://docs.etherscan.io/api-endpoints/contracts#verify-source-code
+
+const (
+	etherscanSingleFile   = "solidity-single-file"
+	etherscanStandardJson = "solidity-standard-json-input"
+
+	etherscanMaxLibraries = 10
+)
+
+type etherscanResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Result  string `json:"result"`
+}
+
+// etherscanVerifyRequest holds the form fields of action=verifysourcecode
+type etherscanVerifyRequest struct {
+	ChainId          string
+	ContractAddress  string
+	SourceCode       string
+	CodeFormat       string
+	ContractName     string
+	CompilerVersion  string
+	OptimizationUsed string
+	Runs             string
+	ConstructorArgs  string
+	EvmVersion       string
+	Libraries        map[string]string
+}
+
+func parseEtherscanVerifyRequest(r *http.Request) *etherscanVerifyRequest {
+	req := etherscanVerifyRequest{
+		ChainId:          r.FormValue("chainid"),
+		ContractAddress:  r.FormValue("contractaddress"),
+		SourceCode:       r.FormValue("sourceCode"),
+		CodeFormat:       r.FormValue("codeformat"),
+		ContractName:     r.FormValue("contractname"),
+		CompilerVersion:  r.FormValue("compilerversion"),
+		OptimizationUsed: r.FormValue("optimizationUsed"),
+		Runs:             r.FormValue("runs"),
+		// the misspelling is part of the etherscan API
+		ConstructorArgs: r.FormValue("constructorArguements"),
+		EvmVersion:      r.FormValue("evmversion"),
+		Libraries:       make(map[string]string),
+	}
+	for i := 1; i <= etherscanMaxLibraries; i++ {
+		name := strings.TrimSpace(r.FormValue(fmt.Sprintf("libraryname%d", i)))
+		address := strings.TrimSpace(r.FormValue(fmt.Sprintf("libraryaddress%d", i)))
+		if name != "" && address != "" {
+			req.Libraries[name] = address
+		}
+	}
+	return &req
+}
+
+// toVerificationRequest converts the etherscan form into the SolcMetadata model
+func (e *etherscanVerifyRequest) toVerificationRequest() (*VerificationRequest, error) {
+	if e.ChainId == "" {
+		return nil, errors.New("missing chainid parameter")
+	}
+	chain, err := strconv.ParseInt(e.ChainId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chainid %s", e.ChainId)
+	}
+	if e.SourceCode == "" || e.ContractName == "" {
+		return nil, errors.New("missing sourceCode or contractname parameter")
+	}
+	req := VerificationRequest{
+		Address:         e.ContractAddress,
+		Chain:           chain,
+		CompilerVersion: e.CompilerVersion,
+		ConstructorArgs: e.ConstructorArgs,
+	}
+	sourcePath, contractName := splitContractName(e.ContractName)
+
+	var metadata SolcMetadata
+	switch e.CodeFormat {
+	case etherscanStandardJson:
+		if sourcePath == "" {
+			return nil, InvalidContractName
+		}
+		req.StandardJson = e.SourceCode
+		req.ContractName = e.ContractName
+		return &req, nil
+	case etherscanSingleFile, "":
+		if sourcePath == "" {
+			sourcePath = contractName + ".sol"
+		}
+		metadata.Language = "Solidity"
+		metadata.Sources = SourcesCode{sourcePath: {Content: e.SourceCode}}
+		metadata.Settings.Optimizer.Enabled = e.OptimizationUsed == "1"
+		metadata.Settings.Optimizer.Runs = 200
+		if e.Runs != "" {
+			if metadata.Settings.Optimizer.Runs, err = strconv.Atoi(e.Runs); err != nil {
+				return nil, fmt.Errorf("invalid runs %s", e.Runs)
+			}
+		}
+		if e.EvmVersion != "" && e.EvmVersion != "default" {
+			metadata.Settings.EvmVersion = e.EvmVersion
+		}
+		if len(e.Libraries) > 0 {
+			libraries := make(map[string]interface{}, len(e.Libraries))
+			for name, address := range e.Libraries {
+				libraries[name] = address
+			}
+			metadata.Settings.Libraries = map[string]interface{}{sourcePath: libraries}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported codeformat %s", e.CodeFormat)
+	}
+	metadata.Settings.CompilationTarget = map[string]string{sourcePath: contractName}
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	req.Metadata = string(raw)
+	return &req, nil
+}
+
+// /api?module=contract&action=verifysourcecode|checkverifystatus
+func etherscanHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondEtherscan(w, "0", "NOTOK", err.Error())
+		return
+	}
+	if r.FormValue("module") != "contract" {
+		respondEtherscan(w, "0", "NOTOK", "Error! Missing Or invalid Module name")
+		return
+	}
+	switch r.FormValue("action") {
+	case "verifysourcecode":
+		etherscanVerifyHandler(w, r)
+	case "checkverifystatus":
+		etherscanCheckStatusHandler(w, r)
+	default:
+		respondEtherscan(w, "0", "NOTOK", "Error! Missing Or invalid Action name")
+	}
+}
+
+func etherscanVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseEtherscanVerifyRequest(r).toVerificationRequest()
+	if err != nil {
+		respondEtherscan(w, "0", "NOTOK", err.Error())
+		return
+	}
+	if err = req.validate(); err != nil {
+		respondEtherscan(w, "0", "NOTOK", err.Error())
+		return
+	}
+	job, err := JobManagerInstance.Submit(req)
+	if err != nil {
+		respondEtherscan(w, "0", "NOTOK", err.Error())
+		return
+	}
+	respondEtherscan(w, "1", "OK", job.ID)
+}
+
+func etherscanCheckStatusHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := JobManagerInstance.Get(r.FormValue("guid"))
+	if !ok {
+		respondEtherscan(w, "0", "NOTOK", "Fail - Unable to verify. "+ErrJobNotFound.Error())
+		return
+	}
+	if job.Status != jobDone {
+		respondEtherscan(w, "0", "NOTOK", "Pending in queue")
+		return
+	}
+	if job.Result.VerifiedStatus == mismatch {
+		respondEtherscan(w, "0", "NOTOK", "Fail - Unable to verify. "+job.Result.Message)
+		return
+	}
+	respondEtherscan(w, "1", "OK", "Pass - Verified")
+}
+
+func respondEtherscan(w http.ResponseWriter, status, message, result string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(etherscanResponse{Status: status, Message: message, Result: result})
+}
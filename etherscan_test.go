@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_etherscanToVerificationRequest(t *testing.T) {
+	form := url.Values{
+		"chainid":               {"46"},
+		"contractaddress":       {"0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f"},
+		"sourceCode":            {"pragma solidity ^0.8.0;\ncontract YourContract {}"},
+		"codeformat":            {etherscanSingleFile},
+		"contractname":          {"YourContract"},
+		"compilerversion":       {"v0.8.9+commit.e5eed63a"},
+		"optimizationUsed":      {"1"},
+		"runs":                  {"999"},
+		"constructorArguements": {"000000000000000000000000000000000000000000000000000000000000002a"},
+		"evmversion":            {"istanbul"},
+		"libraryname1":          {"SafeMath"},
+		"libraryaddress1":       {"0x0000000000000000000000000000000000000001"},
+	}
+	r := httptest.NewRequest("POST", "/api?module=contract&action=verifysourcecode", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_ = r.ParseForm()
+
+	req, err := parseEtherscanVerifyRequest(r).toVerificationRequest()
+	if err != nil {
+		t.Fatalf("toVerificationRequest failed: %v", err)
+	}
+	if req.Chain != 46 || req.ConstructorArgs == "" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	var metadata SolcMetadata
+	if err = json.Unmarshal([]byte(req.Metadata), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	target, name := metadata.PickComplicationTarget()
+	if target != "YourContract.sol" || name != "YourContract" {
+		t.Errorf("unexpected compilation target %s:%s", target, name)
+	}
+	if !metadata.Settings.Optimizer.Enabled || metadata.Settings.Optimizer.Runs != 999 || metadata.Settings.EvmVersion != "istanbul" {
+		t.Errorf("unexpected settings %+v", metadata.Settings)
+	}
+	if libraries, ok := metadata.Settings.Libraries["YourContract.sol"].(map[string]interface{}); !ok || libraries["SafeMath"] != "0x0000000000000000000000000000000000000001" {
+		t.Errorf("unexpected libraries %+v", metadata.Settings.Libraries)
+	}
+
+	standard := etherscanVerifyRequest{
+		ChainId:         "46",
+		SourceCode:      `{"language":"Solidity","sources":{"contracts/new.sol":{"content":"contract YourContract {}"}},"settings":{"optimizer":{"enabled":false,"runs":200}}}`,
+		CodeFormat:      etherscanStandardJson,
+		ContractName:    "YourContract",
+		CompilerVersion: "v0.8.9+commit.e5eed63a",
+	}
+	if _, err = standard.toVerificationRequest(); err == nil {
+		t.Errorf("expected error for standard json input without source path")
+	}
+	standard.ContractName = "contracts/new.sol:YourContract"
+	if req, err = standard.toVerificationRequest(); err != nil || req.StandardJson != standard.SourceCode || req.ContractName != standard.ContractName {
+		t.Errorf("unexpected standard json conversion %v %+v", err, req)
+	}
+}
+
+func Test_etherscanHandler(t *testing.T) {
+	JobManagerInstance = newTestJobManager(func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error) {
+		return &VerificationResponse{VerifiedStatus: perfect, Message: "ok"}, nil
+	})
+
+	form := url.Values{
+		"module":          {"contract"},
+		"action":          {"verifysourcecode"},
+		"contractaddress": {"0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f"},
+		"sourceCode":      {"contract YourContract {}"},
+		"contractname":    {"YourContract"},
+		"compilerversion": {"v0.8.9+commit.e5eed63a"},
+	}
+	r := httptest.NewRequest("POST", "/api?chainid=46", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	etherscanHandler(rr, r)
+
+	var resp etherscanResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.Status != "1" || resp.Result == "" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	waitJob(t, JobManagerInstance, resp.Result)
+
+	rr = httptest.NewRecorder()
+	etherscanHandler(rr, httptest.NewRequest("GET", "/api?module=contract&action=checkverifystatus&guid="+resp.Result, nil))
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.Status != "1" || resp.Result != "Pass - Verified" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	etherscanHandler(rr, httptest.NewRequest("GET", "/api?module=contract&action=verifysourcecode", nil))
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if resp.Status != "0" || resp.Result != "missing chainid parameter" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
@@ -0,0 +1,143 @@
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"verify-golang/util"
+)
+
+// Instruction is one disassembled instruction, Immediate holds push data or EOF immediates
+// and is cut short when the code ends in the middle of it
+type Instruction struct {
+	Offset    int
+	Op        OpCode
+	Immediate []byte
+}
+
+func (i Instruction) String() string {
+	if len(i.Immediate) > 0 {
+		return fmt.Sprintf("%04x: %s 0x%s", i.Offset, i.Op, hex.EncodeToString(i.Immediate))
+	}
+	return fmt.Sprintf("%04x: %s", i.Offset, i.Op)
+}
+
+func (i Instruction) MarshalJSON() ([]byte, error) {
+	listing := struct {
+		Offset    int    `json:"offset"`
+		Op        string `json:"op"`
+		Immediate string `json:"immediate,omitempty"`
+	}{Offset: i.Offset, Op: i.Op.String()}
+	if len(i.Immediate) > 0 {
+		listing.Immediate = "0x" + hex.EncodeToString(i.Immediate)
+	}
+	return json.Marshal(listing)
+}
+
+// Size is the number of bytes the instruction takes, opcode included
+func (i Instruction) Size() int {
+	return 1 + len(i.Immediate)
+}
+
+// Disassemble lists the instructions of legacy code, skipping push data
+func Disassemble(code []byte) []Instruction {
+	return disassemble(code, false)
+}
+
+// DisassembleEOF lists the instructions of one EOF code section, with the EOF immediates
+func DisassembleEOF(code []byte) []Instruction {
+	return disassemble(code, true)
+}
+
+func disassemble(code []byte, eof bool) []Instruction {
+	var instructions []Instruction
+	for pc := 0; pc < len(code); {
+		op := OpCode(code[pc])
+		size := 0
+		if eof {
+			size = eofImmediateSize(op, code, pc)
+		} else if op.IsPush() {
+			size = int(op-PUSH1) + 1
+		}
+		end := min(pc+1+size, len(code))
+		instructions = append(instructions, Instruction{Offset: pc, Op: op, Immediate: code[pc+1 : end]})
+		pc = end
+	}
+	return instructions
+}
+
+// InstructionAt returns the index of the instruction covering offset, len(instructions) past the end
+func InstructionAt(instructions []Instruction, offset int) int {
+	for i, ins := range instructions {
+		if ins.Offset+ins.Size() > offset {
+			return i
+		}
+	}
+	return len(instructions)
+}
+
+// Uses reports whether op appears as an instruction rather than as push data
+func Uses(code []byte, op OpCode) bool {
+	for _, ins := range Disassemble(code) {
+		if ins.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Layout splits legacy code into the executable part, the data appended after it, such as the runtime
+// code inside creation code, and the CBOR metadata with its two byte length
+type Layout struct {
+	Code     []byte
+	Data     []byte
+	Metadata []byte
+}
+
+// Split finds the layout of legacy code. The CBOR metadata is recognised by its length suffix, data starts
+// at the first byte that cannot be reached: one following a halting instruction that is not a JUMPDEST.
+// A single INVALID separating code from data, as solc emits it, stays with the code
+func Split(code []byte) Layout {
+	layout := Layout{Code: code}
+	if size := MetadataLength(code); size > 0 {
+		layout.Code, layout.Metadata = code[:len(code)-size], code[len(code)-size:]
+	}
+
+	instructions := Disassemble(layout.Code)
+	for i, ins := range instructions {
+		if !ins.Op.halts() || i+1 == len(instructions) {
+			continue
+		}
+		next := instructions[i+1]
+		if next.Op == JUMPDEST {
+			continue
+		}
+		end := next.Offset
+		if next.Op == INVALID && ins.Op != INVALID {
+			end++
+		}
+		layout.Code, layout.Data = layout.Code[:end], layout.Code[end:]
+		break
+	}
+	return layout
+}
+
+// MetadataLength is the size of the CBOR metadata ending code, length suffix included, 0 when there is none
+func MetadataLength(code []byte) int {
+	if len(code) < 2 {
+		return 0
+	}
+	size := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	if size == 0 || size+2 > len(code) {
+		return 0
+	}
+	blob := code[len(code)-2-size : len(code)-2]
+	decoded, n, err := util.DecodeCBOR(blob)
+	if err != nil || n != len(blob) {
+		return 0
+	}
+	if _, ok := decoded.(map[string]any); !ok {
+		return 0
+	}
+	return size + 2
+}
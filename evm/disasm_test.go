@@ -0,0 +1,68 @@
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+const testMetadata = "a26469706673582212208c0f254fc3c3b217e54226bdd6f2f788a6b770c6a3c09ea3501cfe671333744564736f6c6343000813" + "0033"
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDisassemble(t *testing.T) {
+	// the 0x5b inside PUSH2 data is not a JUMPDEST
+	instructions := Disassemble(decodeHex(t, "6080615b5b5b00"+"61ff"))
+	expected := []string{"0000: PUSH1 0x80", "0002: PUSH2 0x5b5b", "0005: JUMPDEST", "0006: STOP", "0007: PUSH2 0xff"}
+	if len(instructions) != len(expected) {
+		t.Fatalf("got %d instructions, want %d", len(instructions), len(expected))
+	}
+	for i, ins := range instructions {
+		if ins.String() != expected[i] {
+			t.Errorf("instruction %d = %s; want %s", i, ins, expected[i])
+		}
+	}
+	if index := InstructionAt(instructions, 3); index != 1 {
+		t.Errorf("InstructionAt(3) = %d; want 1", index)
+	}
+
+	raw, err := json.Marshal(instructions[1])
+	if err != nil || string(raw) != `{"offset":2,"op":"PUSH2","immediate":"0x5b5b"}` {
+		t.Errorf("unexpected json %s %v", raw, err)
+	}
+
+	if Uses(decodeHex(t, "605f00"), PUSH0) || !Uses(decodeHex(t, "5f5f00"), PUSH0) {
+		t.Errorf("Uses looked into push data")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	// creation code: constructor, RETURN, INVALID separator, then the runtime code with its metadata
+	constructor := "6080604052348015600f57600080fd5b50603f80601d6000396000f3"
+	runtime := "6080604052600080fd"
+	layout := Split(decodeHex(t, constructor+"fe"+runtime+"fe"+testMetadata))
+	if hex.EncodeToString(layout.Code) != constructor+"fe" {
+		t.Errorf("code = %x", layout.Code)
+	}
+	if hex.EncodeToString(layout.Data) != runtime+"fe" || hex.EncodeToString(layout.Metadata) != testMetadata {
+		t.Errorf("data = %x, metadata = %x", layout.Data, layout.Metadata)
+	}
+
+	// runtime code, every halt is followed by a jump target
+	code := "6080604052600a565b005b600080fd" + "fe"
+	layout = Split(decodeHex(t, code+testMetadata))
+	if hex.EncodeToString(layout.Code) != code || len(layout.Data) != 0 {
+		t.Errorf("code = %x, data = %x", layout.Code, layout.Data)
+	}
+
+	if size := MetadataLength(decodeHex(t, "6080604052")); size != 0 {
+		t.Errorf("MetadataLength without metadata = %d", size)
+	}
+}
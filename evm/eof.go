@@ -0,0 +1,312 @@
+package evm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EOF container (EIP-3540, EIP-7620) as emitted by solc with settings.eofVersion:
+// magic 0xef00, version, type, code, container and data section headers, terminator, then the bodies
+
+const (
+	eofMagic0 = 0xef
+	eofMagic1 = 0x00
+
+	eofKindTypes     = 0x01
+	eofKindCode      = 0x02
+	eofKindContainer = 0x03
+	eofKindData      = 0xff
+	eofTerminator    = 0x00
+
+	eofTypeSize = 4
+)
+
+var ErrNotEOF = errors.New("eof: missing 0xef00 magic")
+
+// TypeSection describes the inputs, outputs and stack use of one code section
+type TypeSection struct {
+	Inputs         byte   `json:"inputs"`
+	Outputs        byte   `json:"outputs"`
+	MaxStackHeight uint16 `json:"max_stack_height"`
+}
+
+// Container is a parsed EOF container. Data may be shorter than DataSize in
+// initcode containers, the rest is appended at deploy time
+type Container struct {
+	Version    byte
+	Types      []TypeSection
+	Code       [][]byte
+	Containers [][]byte
+	Data       []byte
+	DataSize   int
+}
+
+// IsEOF reports whether code starts with the EOF magic, which legacy code can no longer start with (EIP-3541)
+func IsEOF(code []byte) bool {
+	return len(code) >= 2 && code[0] == eofMagic0 && code[1] == eofMagic1
+}
+
+type eofReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *eofReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errors.New("eof: truncated header")
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+func (r *eofReader) uint(size int) (int, error) {
+	if r.pos+size > len(r.data) {
+		return 0, errors.New("eof: truncated header")
+	}
+	var value int
+	for _, b := range r.data[r.pos : r.pos+size] {
+		value = value<<8 | int(b)
+	}
+	r.pos += size
+	return value, nil
+}
+
+func (r *eofReader) expect(kind byte) error {
+	b, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if b != kind {
+		return fmt.Errorf("eof: expected section kind 0x%02x at %d, found 0x%02x", kind, r.pos-1, b)
+	}
+	return nil
+}
+
+// sizes reads a section count followed by one size of width bytes per section
+func (r *eofReader) sizes(width int) ([]int, error) {
+	count, err := r.uint(2)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("eof: empty section list at %d", r.pos-2)
+	}
+	sizes := make([]int, count)
+	for i := range sizes {
+		if sizes[i], err = r.uint(width); err != nil {
+			return nil, err
+		}
+		if sizes[i] == 0 {
+			return nil, fmt.Errorf("eof: empty section at %d", r.pos-width)
+		}
+	}
+	return sizes, nil
+}
+
+// ParseEOF parses the header and splits the body of an EOF container
+func ParseEOF(code []byte) (*Container, error) {
+	if !IsEOF(code) {
+		return nil, ErrNotEOF
+	}
+	r := &eofReader{data: code, pos: 2}
+	version, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("eof: unsupported version %d", version)
+	}
+
+	if err = r.expect(eofKindTypes); err != nil {
+		return nil, err
+	}
+	typesSize, err := r.uint(2)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.expect(eofKindCode); err != nil {
+		return nil, err
+	}
+	codeSizes, err := r.sizes(2)
+	if err != nil {
+		return nil, err
+	}
+	if typesSize != len(codeSizes)*eofTypeSize {
+		return nil, fmt.Errorf("eof: type section size %d does not fit %d code sections", typesSize, len(codeSizes))
+	}
+
+	kind, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	var containerSizes []int
+	if kind == eofKindContainer {
+		// container sizes are 4 bytes wide, code sizes 2
+		if containerSizes, err = r.sizes(4); err != nil {
+			return nil, err
+		}
+		if kind, err = r.byte(); err != nil {
+			return nil, err
+		}
+	}
+	if kind != eofKindData {
+		return nil, fmt.Errorf("eof: expected data section kind at %d, found 0x%02x", r.pos-1, kind)
+	}
+	dataSize, err := r.uint(2)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.expect(eofTerminator); err != nil {
+		return nil, err
+	}
+
+	container := &Container{Version: version, DataSize: dataSize}
+	body := code[r.pos:]
+	take := func(size int) ([]byte, error) {
+		if size > len(body) {
+			return nil, errors.New("eof: truncated body")
+		}
+		section := body[:size]
+		body = body[size:]
+		return section, nil
+	}
+
+	types, err := take(typesSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(types); i += eofTypeSize {
+		container.Types = append(container.Types, TypeSection{
+			Inputs:         types[i],
+			Outputs:        types[i+1],
+			MaxStackHeight: binary.BigEndian.Uint16(types[i+2:]),
+		})
+	}
+	for _, size := range codeSizes {
+		section, err := take(size)
+		if err != nil {
+			return nil, err
+		}
+		container.Code = append(container.Code, section)
+	}
+	for _, size := range containerSizes {
+		section, err := take(size)
+		if err != nil {
+			return nil, err
+		}
+		container.Containers = append(container.Containers, section)
+	}
+	if len(body) > dataSize {
+		return nil, fmt.Errorf("eof: %d bytes of data exceed the declared %d", len(body), dataSize)
+	}
+	container.Data = body
+	return container, nil
+}
+
+// MetadataRange locates the CBOR metadata solc places at the end of the static data, before the aux data
+// appended at deploy time. Without the compiled container the end is unknown, the last position followed
+// by a valid CBOR map and its two byte length is taken. ok is false when the data holds no metadata
+func (c *Container) MetadataRange() (start, end int, ok bool) {
+	for end = len(c.Data); end >= 2; end-- {
+		if size := MetadataLength(c.Data[:end]); size > 0 {
+			return end - size, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// EOFDifference locates the first difference between a compiled and a deployed container
+type EOFDifference struct {
+	// Section is header, types, code N, container N, data or metadata, sections of nested
+	// containers are prefixed with their container: "container 0/code 1"
+	Section string
+	// Offset is relative to the start of the section
+	Offset int
+	// Compiled and Deployed hold the differing code section of each container, nil for other sections
+	Compiled, Deployed []byte
+}
+
+// MetadataOnly reports a difference limited to the CBOR metadata, of the container or a nested one
+func (d *EOFDifference) MetadataOnly() bool {
+	return d.Section == "metadata" || strings.HasSuffix(d.Section, "/metadata")
+}
+
+func diffOffset(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) == len(b) {
+		return -1
+	}
+	return min(len(a), len(b))
+}
+
+// CompareEOF compares a compiled container with a deployed one section by section, nested containers
+// included. The deployed data may extend the compiled data with aux data, such as immutables, which is
+// not compared. The result is nil when both match and a metadata difference is only returned when no
+// other section differs
+func CompareEOF(compiled, deployed []byte) (*EOFDifference, error) {
+	c, err := ParseEOF(compiled)
+	if err != nil {
+		return nil, fmt.Errorf("compiled code: %w", err)
+	}
+	d, err := ParseEOF(deployed)
+	if err != nil {
+		return nil, fmt.Errorf("deployed code: %w", err)
+	}
+	return compareContainers(c, d)
+}
+
+func compareContainers(c, d *Container) (*EOFDifference, error) {
+	if c.Version != d.Version || len(c.Code) != len(d.Code) || len(c.Containers) != len(d.Containers) {
+		return &EOFDifference{Section: "header"}, nil
+	}
+	for i := range c.Types {
+		if c.Types[i] != d.Types[i] {
+			return &EOFDifference{Section: "types", Offset: i * eofTypeSize}, nil
+		}
+	}
+	for i := range c.Code {
+		if offset := diffOffset(c.Code[i], d.Code[i]); offset >= 0 {
+			return &EOFDifference{Section: fmt.Sprintf("code %d", i), Offset: offset, Compiled: c.Code[i], Deployed: d.Code[i]}, nil
+		}
+	}
+
+	var metadataDiff *EOFDifference
+	for i := range c.Containers {
+		diff, err := CompareEOF(c.Containers[i], d.Containers[i])
+		if err != nil {
+			return nil, fmt.Errorf("container %d: %w", i, err)
+		}
+		if diff == nil {
+			continue
+		}
+		diff.Section = fmt.Sprintf("container %d/%s", i, diff.Section)
+		if !diff.MetadataOnly() {
+			return diff, nil
+		}
+		if metadataDiff == nil {
+			metadataDiff = diff
+		}
+	}
+
+	// the compiled data ends with the metadata, the deployed data may carry aux data after it
+	static := len(c.Data) - MetadataLength(c.Data)
+	if len(d.Data) < len(c.Data) {
+		return &EOFDifference{Section: "data", Offset: diffOffset(c.Data, d.Data)}, nil
+	}
+	if offset := diffOffset(c.Data[:static], d.Data[:static]); offset >= 0 {
+		return &EOFDifference{Section: "data", Offset: offset}, nil
+	}
+	if c.DataSize != d.DataSize {
+		return &EOFDifference{Section: "header"}, nil
+	}
+	if offset := diffOffset(c.Data[static:], d.Data[static:len(c.Data)]); offset >= 0 {
+		return &EOFDifference{Section: "metadata", Offset: offset}, nil
+	}
+	return metadataDiff, nil
+}
@@ -0,0 +1,140 @@
+package evm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// a container holding one code section, RJUMP 0 and STOP, one sub container and two of four data bytes
+const testInnerContainer = "ef0001" + "010004" + "0200010001" + "ff0000" + "00" + "00800000" + "00"
+
+func TestParseEOF(t *testing.T) {
+	code := "ef0001" + "010004" + "0200010004" + "030001" + "00000014" + "ff0004" + "00" +
+		"00800001" + "e0000000" + testInnerContainer + "aabb"
+	container, err := ParseEOF(decodeHex(t, code))
+	if err != nil {
+		t.Fatalf("ParseEOF failed: %v", err)
+	}
+	if container.Version != 1 || len(container.Types) != 1 || container.Types[0].Outputs != 0x80 || container.Types[0].MaxStackHeight != 1 {
+		t.Errorf("unexpected types %+v", container.Types)
+	}
+	if len(container.Code) != 1 || hex.EncodeToString(container.Code[0]) != "e0000000" {
+		t.Errorf("unexpected code %x", container.Code)
+	}
+	if len(container.Containers) != 1 || hex.EncodeToString(container.Containers[0]) != testInnerContainer {
+		t.Errorf("unexpected containers %x", container.Containers)
+	}
+	if hex.EncodeToString(container.Data) != "aabb" || container.DataSize != 4 {
+		t.Errorf("unexpected data %x of %d", container.Data, container.DataSize)
+	}
+
+	instructions := DisassembleEOF(container.Code[0])
+	if len(instructions) != 2 || instructions[0].String() != "0000: RJUMP 0x0000" || instructions[1].Op != STOP {
+		t.Errorf("unexpected instructions %v", instructions)
+	}
+
+	inner, err := ParseEOF(container.Containers[0])
+	if err != nil || len(inner.Containers) != 0 || len(inner.Data) != 0 {
+		t.Errorf("unexpected inner container %+v %v", inner, err)
+	}
+
+	for _, bad := range []string{
+		"6080604052",                    // legacy
+		"ef0002010004",                  // version 2
+		"ef000101000402000100",          // truncated header
+		"ef000101000802000100010400000", // type size for two sections
+		code + "ccddeeff",               // more data than declared
+		code[:len(code)-len(testInnerContainer)-4],
+	} {
+		if len(bad)%2 != 0 {
+			bad += "0"
+		}
+		if _, err = ParseEOF(decodeHex(t, bad)); err == nil {
+			t.Errorf("ParseEOF(%s) expected error", bad)
+		}
+	}
+}
+
+// testEOF builds a container with non-returning code sections and the given data size
+func testEOF(codes, containers []string, data string, dataSize int) string {
+	header := fmt.Sprintf("ef0001"+"01%04x"+"02%04x", len(codes)*4, len(codes))
+	body := strings.Repeat("00800000", len(codes))
+	for _, code := range codes {
+		header += fmt.Sprintf("%04x", len(code)/2)
+		body += code
+	}
+	if len(containers) > 0 {
+		header += fmt.Sprintf("03%04x", len(containers))
+		for _, container := range containers {
+			header += fmt.Sprintf("%08x", len(container)/2)
+			body += container
+		}
+	}
+	return header + fmt.Sprintf("ff%04x00", dataSize) + body + data
+}
+
+func TestCompareEOF(t *testing.T) {
+	metadata := testMetadata
+	otherMetadata := strings.Replace(metadata, "8c0f", "0000", 1)
+	static := "aabb"
+	code := "5f5f5260206000f3"
+	compiledData := static + metadata
+	// the deployed data carries a 32 byte immutable after the metadata
+	dataSize := len(compiledData)/2 + 32
+	immutable := strings.Repeat("11", 32)
+	inner := testEOF([]string{"00"}, nil, metadata, len(metadata)/2)
+	compiled := testEOF([]string{code, "e4"}, []string{inner}, compiledData, dataSize)
+
+	tests := []struct {
+		name     string
+		deployed string
+		section  string
+		offset   int
+	}{
+		{"identical", testEOF([]string{code, "e4"}, []string{inner}, compiledData+immutable, dataSize), "", 0},
+		{"metadata", testEOF([]string{code, "e4"}, []string{inner}, static+otherMetadata+immutable, dataSize), "metadata", 10},
+		{"nested metadata", testEOF([]string{code, "e4"}, []string{testEOF([]string{"00"}, nil, otherMetadata, len(metadata)/2)}, compiledData+immutable, dataSize), "container 0/metadata", 10},
+		{"code", testEOF([]string{"5f5f5260206001f3", "e4"}, []string{inner}, compiledData+immutable, dataSize), "code 0", 6},
+		{"second code", testEOF([]string{code, "e400"}, []string{inner}, compiledData+immutable, dataSize), "code 1", 1},
+		{"data", testEOF([]string{code, "e4"}, []string{inner}, "aacc"+metadata+immutable, dataSize), "data", 1},
+		{"sections", testEOF([]string{code}, []string{inner}, compiledData+immutable, dataSize), "header", 0},
+		{"data size", testEOF([]string{code, "e4"}, []string{inner}, compiledData+immutable+"00", dataSize+1), "header", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := CompareEOF(decodeHex(t, compiled), decodeHex(t, tt.deployed))
+			if err != nil {
+				t.Fatalf("CompareEOF failed: %v", err)
+			}
+			if tt.section == "" {
+				if diff != nil {
+					t.Errorf("unexpected difference %+v", diff)
+				}
+				return
+			}
+			if diff == nil || diff.Section != tt.section || diff.Offset != tt.offset {
+				t.Fatalf("got %+v, want %s at %d", diff, tt.section, tt.offset)
+			}
+			if diff.MetadataOnly() != strings.HasSuffix(tt.section, "metadata") {
+				t.Errorf("MetadataOnly() = %v", diff.MetadataOnly())
+			}
+			if strings.HasPrefix(tt.section, "code") && (diff.Compiled == nil || diff.Deployed == nil) {
+				t.Errorf("differing code sections not returned")
+			}
+		})
+	}
+
+	if _, err := CompareEOF(decodeHex(t, compiled), decodeHex(t, "6080604052")); err == nil {
+		t.Errorf("expected an error comparing with legacy code")
+	}
+
+	container, err := ParseEOF(decodeHex(t, testEOF([]string{code}, nil, compiledData+immutable, dataSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start, end, ok := container.MetadataRange(); !ok || start != 2 || end != len(compiledData)/2 {
+		t.Errorf("MetadataRange() = %d, %d, %v", start, end, ok)
+	}
+}
@@ -0,0 +1,107 @@
+package evm
+
+import "fmt"
+
+// OpCode is a single EVM instruction byte
+type OpCode byte
+
+const (
+	STOP         OpCode = 0x00
+	JUMP         OpCode = 0x56
+	JUMPDEST     OpCode = 0x5b
+	MCOPY        OpCode = 0x5e
+	PUSH0        OpCode = 0x5f
+	PUSH1        OpCode = 0x60
+	PUSH32       OpCode = 0x7f
+	DATALOADN    OpCode = 0xd1
+	RJUMP        OpCode = 0xe0
+	RJUMPI       OpCode = 0xe1
+	RJUMPV       OpCode = 0xe2
+	CALLF        OpCode = 0xe3
+	RETF         OpCode = 0xe4
+	JUMPF        OpCode = 0xe5
+	DUPN         OpCode = 0xe6
+	SWAPN        OpCode = 0xe7
+	EXCHANGE     OpCode = 0xe8
+	EOFCREATE    OpCode = 0xec
+	RETURNCODE   OpCode = 0xee
+	RETURN       OpCode = 0xf3
+	DELEGATECALL OpCode = 0xf4
+	REVERT       OpCode = 0xfd
+	INVALID      OpCode = 0xfe
+	SELFDESTRUCT OpCode = 0xff
+)
+
+var opCodeNames = map[OpCode]string{
+	0x00: "STOP", 0x01: "ADD", 0x02: "MUL", 0x03: "SUB", 0x04: "DIV", 0x05: "SDIV", 0x06: "MOD", 0x07: "SMOD",
+	0x08: "ADDMOD", 0x09: "MULMOD", 0x0a: "EXP", 0x0b: "SIGNEXTEND",
+	0x10: "LT", 0x11: "GT", 0x12: "SLT", 0x13: "SGT", 0x14: "EQ", 0x15: "ISZERO", 0x16: "AND", 0x17: "OR",
+	0x18: "XOR", 0x19: "NOT", 0x1a: "BYTE", 0x1b: "SHL", 0x1c: "SHR", 0x1d: "SAR",
+	0x20: "KECCAK256",
+	0x30: "ADDRESS", 0x31: "BALANCE", 0x32: "ORIGIN", 0x33: "CALLER", 0x34: "CALLVALUE", 0x35: "CALLDATALOAD",
+	0x36: "CALLDATASIZE", 0x37: "CALLDATACOPY", 0x38: "CODESIZE", 0x39: "CODECOPY", 0x3a: "GASPRICE",
+	0x3b: "EXTCODESIZE", 0x3c: "EXTCODECOPY", 0x3d: "RETURNDATASIZE", 0x3e: "RETURNDATACOPY", 0x3f: "EXTCODEHASH",
+	0x40: "BLOCKHASH", 0x41: "COINBASE", 0x42: "TIMESTAMP", 0x43: "NUMBER", 0x44: "PREVRANDAO", 0x45: "GASLIMIT",
+	0x46: "CHAINID", 0x47: "SELFBALANCE", 0x48: "BASEFEE", 0x49: "BLOBHASH", 0x4a: "BLOBBASEFEE",
+	0x50: "POP", 0x51: "MLOAD", 0x52: "MSTORE", 0x53: "MSTORE8", 0x54: "SLOAD", 0x55: "SSTORE", 0x56: "JUMP",
+	0x57: "JUMPI", 0x58: "PC", 0x59: "MSIZE", 0x5a: "GAS", 0x5b: "JUMPDEST", 0x5c: "TLOAD", 0x5d: "TSTORE",
+	0x5e: "MCOPY", 0x5f: "PUSH0",
+	0xa0: "LOG0", 0xa1: "LOG1", 0xa2: "LOG2", 0xa3: "LOG3", 0xa4: "LOG4",
+	0xd0: "DATALOAD", 0xd1: "DATALOADN", 0xd2: "DATASIZE", 0xd3: "DATACOPY",
+	0xe0: "RJUMP", 0xe1: "RJUMPI", 0xe2: "RJUMPV", 0xe3: "CALLF", 0xe4: "RETF", 0xe5: "JUMPF",
+	0xe6: "DUPN", 0xe7: "SWAPN", 0xe8: "EXCHANGE", 0xec: "EOFCREATE", 0xee: "RETURNCODE",
+	0xf0: "CREATE", 0xf1: "CALL", 0xf2: "CALLCODE", 0xf3: "RETURN", 0xf4: "DELEGATECALL", 0xf5: "CREATE2",
+	0xf7: "RETURNDATALOAD", 0xf8: "EXTCALL", 0xf9: "EXTDELEGATECALL", 0xfa: "STATICCALL", 0xfb: "EXTSTATICCALL",
+	0xfd: "REVERT", 0xfe: "INVALID", 0xff: "SELFDESTRUCT",
+}
+
+func init() {
+	for i := 0; i < 32; i++ {
+		opCodeNames[PUSH1+OpCode(i)] = fmt.Sprintf("PUSH%d", i+1)
+	}
+	for i := 0; i < 16; i++ {
+		opCodeNames[OpCode(0x80+i)] = fmt.Sprintf("DUP%d", i+1)
+		opCodeNames[OpCode(0x90+i)] = fmt.Sprintf("SWAP%d", i+1)
+	}
+}
+
+func (op OpCode) String() string {
+	if name, ok := opCodeNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN_0x%02x", byte(op))
+}
+
+// IsPush reports PUSH1 to PUSH32, the instructions followed by push data
+func (op OpCode) IsPush() bool {
+	return op >= PUSH1 && op <= PUSH32
+}
+
+// halts reports instructions after which the next byte is only reached as a jump target
+func (op OpCode) halts() bool {
+	switch op {
+	case STOP, JUMP, RETURN, REVERT, INVALID, SELFDESTRUCT:
+		return true
+	}
+	return false
+}
+
+// eofImmediateSize is the immediate length of op inside an EOF code section, code[pc] being op
+func eofImmediateSize(op OpCode, code []byte, pc int) int {
+	switch op {
+	case DATALOADN, RJUMP, RJUMPI, CALLF, JUMPF:
+		return 2
+	case DUPN, SWAPN, EXCHANGE, EOFCREATE, RETURNCODE:
+		return 1
+	case RJUMPV:
+		// max_index byte followed by max_index+1 relative offsets
+		if pc+1 < len(code) {
+			return 1 + (int(code[pc+1])+1)*2
+		}
+		return 1
+	}
+	if op.IsPush() {
+		return int(op-PUSH1) + 1
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package evm
+
+import "testing"
+
+func TestOpCodeString(t *testing.T) {
+	tests := []struct {
+		op       OpCode
+		expected string
+	}{
+		{0x00, "STOP"},
+		{0x5f, "PUSH0"},
+		{0x60, "PUSH1"},
+		{0x7f, "PUSH32"},
+		{0x8f, "DUP16"},
+		{0x90, "SWAP1"},
+		{0xe2, "RJUMPV"},
+		{0x0c, "UNKNOWN_0x0c"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.op.String(); result != tt.expected {
+			t.Errorf("OpCode(0x%02x).String() = %v; want %v", byte(tt.op), result, tt.expected)
+		}
+	}
+}
+
+func TestEofImmediateSize(t *testing.T) {
+	// RJUMPV with max_index 2 carries three 2 byte offsets
+	code := []byte{byte(RJUMPV), 0x02, 0, 1, 0, 2, 0, 3}
+	if size := eofImmediateSize(RJUMPV, code, 0); size != 7 {
+		t.Errorf("RJUMPV immediate size = %d; want 7", size)
+	}
+	if size := eofImmediateSize(PUSH1+1, nil, 0); size != 2 {
+		t.Errorf("PUSH2 immediate size = %d; want 2", size)
+	}
+}
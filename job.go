@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+	"verify-golang/util"
+)
+
+const (
+	jobQueued    = "queued"
+	jobCompiling = "compiling"
+	jobComparing = "comparing"
+	jobSearching = "searching"
+	jobDone      = "done"
+)
+
+var (
+	ErrJobQueueFull = errors.New("verification queue is full, please retry later")
+	ErrJobNotFound  = errors.New("job not found")
+)
+
+type VerificationJob struct {
+	ID        string                `json:"id"`
+	Status    string                `json:"status"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	Result    *VerificationResponse `json:"result,omitempty"`
+
+	request *VerificationRequest
+}
+
+type jobProcessor func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error)
+
+// JobManager runs verification requests on a bounded worker pool
+// and keeps finished jobs around for jobTTL so clients can poll the result
+type JobManager struct {
+	mu      sync.RWMutex
+	jobs    map[string]*VerificationJob
+	queue   chan *VerificationJob
+	process jobProcessor
+	timeout time.Duration
+	ttl     time.Duration
+}
+
+var JobManagerInstance *JobManager
+
+func NewJobManager(workers, queueSize int) *JobManager {
+	jm := &JobManager{
+		jobs:  make(map[string]*VerificationJob),
+		queue: make(chan *VerificationJob, queueSize),
+		process: func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error) {
+			return req.verify(ctx, progress)
+		},
+		timeout: time.Duration(util.EnvInt("VERIFY_JOB_TIMEOUT", 600)) * time.Second,
+		ttl:     time.Duration(util.EnvInt("VERIFY_JOB_TTL", 3600)) * time.Second,
+	}
+	for i := 0; i < workers; i++ {
+		go jm.worker()
+	}
+	go jm.cleanup()
+	return jm
+}
+
+// NewJobManagerFromEnv sizes the worker pool with VERIFY_WORKERS and VERIFY_QUEUE_SIZE
+func NewJobManagerFromEnv() *JobManager {
+	return NewJobManager(util.EnvInt("VERIFY_WORKERS", runtime.NumCPU()), util.EnvInt("VERIFY_QUEUE_SIZE", 100))
+}
+
+// Submit enqueues a validated request, it never blocks when the queue is full
+func (jm *JobManager) Submit(req *VerificationRequest) (*VerificationJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &VerificationJob{ID: id, Status: jobQueued, CreatedAt: now, UpdatedAt: now, request: req}
+
+	jm.mu.Lock()
+	jm.jobs[id] = job
+	jm.mu.Unlock()
+
+	select {
+	case jm.queue <- job:
+	default:
+		jm.mu.Lock()
+		delete(jm.jobs, id)
+		jm.mu.Unlock()
+		return nil, ErrJobQueueFull
+	}
+	snapshot, _ := jm.Get(id)
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job, safe to encode while workers keep running
+func (jm *JobManager) Get(id string) (*VerificationJob, bool) {
+	jm.mu.RLock()
+	defer jm.mu.RUnlock()
+	job, ok := jm.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+func (jm *JobManager) setStatus(job *VerificationJob, status string, result *VerificationResponse) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	job.Status = status
+	job.UpdatedAt = time.Now()
+	if result != nil {
+		job.Result = result
+	}
+}
+
+func (jm *JobManager) worker() {
+	for job := range jm.queue {
+		jm.run(job)
+	}
+}
+
+func (jm *JobManager) run(job *VerificationJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), jm.timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			util.Logger().Error(fmt.Errorf("verification job %s panic: %v", job.ID, r))
+			jm.setStatus(job, jobDone, errorResponse(fmt.Errorf("internal error")))
+		}
+	}()
+
+	resp, err := jm.process(ctx, job.request, func(stage string) {
+		jm.setStatus(job, stage, nil)
+	})
+	if err != nil {
+		resp = errorResponse(err)
+	}
+	jm.setStatus(job, jobDone, resp)
+}
+
+// cleanup drops finished jobs older than ttl
+func (jm *JobManager) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		jm.mu.Lock()
+		for id, job := range jm.jobs {
+			if job.Status == jobDone && time.Since(job.UpdatedAt) > jm.ttl {
+				delete(jm.jobs, id)
+			}
+		}
+		jm.mu.Unlock()
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// POST /verify/jobs
+func createJobHandler(w http.ResponseWriter, r *http.Request) {
+	var req VerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		respondError(w, err)
+		return
+	}
+	job, err := JobManagerInstance.Submit(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// GET /verify/jobs/{id}
+func jobStatusHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := JobManagerInstance.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, ErrJobNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(job)
+}
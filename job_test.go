@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestJobManager(process jobProcessor) *JobManager {
+	jm := NewJobManager(1, 1)
+	jm.process = process
+	return jm
+}
+
+func waitJob(t *testing.T, jm *JobManager, id string) *VerificationJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := jm.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status == jobDone {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s not finished", id)
+	return nil
+}
+
+func Test_JobManager(t *testing.T) {
+	jm := newTestJobManager(func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error) {
+		progress(jobCompiling)
+		progress(jobComparing)
+		if req.Address == "0x0000000000000000000000000000000000000000" {
+			return nil, errors.New("bytecode mismatch")
+		}
+		return &VerificationResponse{VerifiedStatus: perfect, Message: "ok"}, nil
+	})
+
+	job, err := jm.Submit(&VerificationRequest{Address: "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	done := waitJob(t, jm, job.ID)
+	if done.Result == nil || done.Result.VerifiedStatus != perfect {
+		t.Errorf("expected perfect result, got %+v", done.Result)
+	}
+
+	job, err = jm.Submit(&VerificationRequest{Address: "0x0000000000000000000000000000000000000000"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	done = waitJob(t, jm, job.ID)
+	if done.Result == nil || done.Result.VerifiedStatus != mismatch || done.Result.Message != "bytecode mismatch" {
+		t.Errorf("expected mismatch result, got %+v", done.Result)
+	}
+}
+
+func Test_JobManagerQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	jm := newTestJobManager(func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error) {
+		<-release
+		return &VerificationResponse{VerifiedStatus: perfect}, nil
+	})
+	defer close(release)
+
+	var err error
+	// one job held by the worker, one in the queue, the rest must be rejected
+	for i := 0; i < 3 && err == nil; i++ {
+		_, err = jm.Submit(&VerificationRequest{})
+	}
+	if !errors.Is(err, ErrJobQueueFull) {
+		t.Errorf("expected ErrJobQueueFull, got %v", err)
+	}
+}
+
+func Test_jobHandlers(t *testing.T) {
+	JobManagerInstance = newTestJobManager(func(ctx context.Context, req *VerificationRequest, progress func(stage string)) (*VerificationResponse, error) {
+		return &VerificationResponse{VerifiedStatus: partial, Message: "ok"}, nil
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /verify/jobs", createJobHandler)
+	mux.HandleFunc("GET /verify/jobs/{id}", jobStatusHandler)
+
+	body, _ := json.Marshal(VerificationRequest{
+		Address:         "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f",
+		Metadata:        `{}`,
+		Chain:           46,
+		CompilerVersion: "0.8.0+commit.c7dfd78e",
+	})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/verify/jobs", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusAccepted)
+	}
+	var job VerificationJob
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil || job.ID == "" {
+		t.Fatalf("could not decode job: %v", err)
+	}
+
+	waitJob(t, JobManagerInstance, job.ID)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/verify/jobs/"+job.ID, nil))
+	if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+		t.Fatalf("could not decode job: %v", err)
+	}
+	if job.Status != jobDone || job.Result == nil || job.Result.VerifiedStatus != partial {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/verify/jobs/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown job, got %v", rr.Code)
+	}
+}
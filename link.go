@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"verify-golang/util"
+)
+
+// LinkReferences is solc's linkReferences output, source file -> library -> placeholder ranges
+type LinkReferences map[string]map[string][]BytecodeRange
+
+const libraryPlaceholderLength = 40
+
+// libraryAddresses flattens settings.libraries to "file:Lib" -> address. It accepts the standard JSON
+// form {"file": {"Lib": "0x.."}} as well as the metadata form {"file:Lib": "0x.."}
+func libraryAddresses(libraries map[string]interface{}) map[string]string {
+	flat := make(map[string]string)
+	for key, value := range libraries {
+		switch v := value.(type) {
+		case string:
+			flat[key] = v
+		case map[string]interface{}:
+			for name, address := range v {
+				if address, ok := address.(string); ok {
+					flat[key+":"+name] = address
+				}
+			}
+		case map[string]string:
+			for name, address := range v {
+				flat[key+":"+name] = address
+			}
+		}
+	}
+	return flat
+}
+
+// nestedLibraries turns "file:Lib" -> address into the standard JSON form solc accepts
+func nestedLibraries(flat map[string]string) map[string]interface{} {
+	nested := make(map[string]interface{})
+	for name, address := range flat {
+		file, library := "", name
+		if index := strings.LastIndex(name, ":"); index >= 0 {
+			file, library = name[:index], name[index+1:]
+		}
+		libraries, _ := nested[file].(map[string]interface{})
+		if libraries == nil {
+			libraries = make(map[string]interface{})
+			nested[file] = libraries
+		}
+		libraries[library] = address
+	}
+	return nested
+}
+
+// linkLibraries fills the library placeholders of compiled code and returns the linked code with the
+// library map. Addresses come from libraries when given, otherwise from the same offset of the on-chain
+// code. Placeholders solc did not report, such as the "__Lib_____" form of compilers before 0.5, are found
+// by scanning. Both codes are hex without 0x, placeholders that do not fit the on-chain code stay unlinked
+func linkLibraries(compiled, chain string, references LinkReferences, libraries map[string]string) (string, map[string]string) {
+	code := []byte(compiled)
+	linked := make(map[string]string, len(libraries))
+	for name, address := range libraries {
+		linked[name] = strings.ToLower(util.AddHex(address))
+	}
+
+	link := func(name string, start int) {
+		end := start + libraryPlaceholderLength
+		if start < 0 || end > len(code) {
+			return
+		}
+		address := util.TrimHex(linked[name])
+		if len(address) != libraryPlaceholderLength {
+			if end > len(chain) {
+				return
+			}
+			address = strings.ToLower(chain[start:end])
+		}
+		copy(code[start:end], address)
+		linked[name] = "0x" + address
+	}
+
+	for file, contracts := range references {
+		for library, ranges := range contracts {
+			for _, ref := range ranges {
+				link(file+":"+library, ref.Start*2)
+			}
+		}
+	}
+	for i := 0; i+libraryPlaceholderLength <= len(code); i += 2 {
+		if code[i] != '_' || code[i+1] != '_' {
+			continue
+		}
+		link(placeholderLibrary(string(code[i:i+libraryPlaceholderLength]), libraries), i)
+		i += libraryPlaceholderLength - 2
+	}
+	return string(code), linked
+}
+
+// placeholderLibrary resolves a placeholder to the fully qualified name of a known library,
+// "__$<keccak256(name)[:17]>$__" from 0.5 on, "__<name padded with _>__" before
+func placeholderLibrary(placeholder string, libraries map[string]string) string {
+	if strings.HasPrefix(placeholder, "__$") && strings.HasSuffix(placeholder, "$__") {
+		for name := range libraries {
+			if hex.EncodeToString(util.Keccak256([]byte(name))[:17]) == placeholder[3:37] {
+				return name
+			}
+		}
+		return placeholder
+	}
+
+	name := strings.TrimRight(placeholder[2:], "_")
+	for known := range libraries {
+		if known == name || strings.HasSuffix(known, ":"+name) {
+			return known
+		}
+		// long names are cut to fit the placeholder
+		if len(name) == libraryPlaceholderLength-4 && strings.HasPrefix(known, name) {
+			return known
+		}
+	}
+	return name
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"verify-golang/util"
+)
+
+const testLibraryAddress = "0x11fea6722e00ba9f43861a6e4da05fecdf9806b7"
+
+func testPlaceholder(name string) string {
+	return "__$" + hex.EncodeToString(util.Keccak256([]byte(name))[:17]) + "$__"
+}
+
+func Test_linkLibraries(t *testing.T) {
+	address := util.TrimHex(testLibraryAddress)
+	compiled := "73" + testPlaceholder("contracts/Lib.sol:Lib") + "5af4"
+	chain := "73" + address + "5af4"
+	references := LinkReferences{"contracts/Lib.sol": {"Lib": {{Start: 1, Length: 20}}}}
+
+	linked, libraries := linkLibraries(compiled, chain, references, nil)
+	if linked != chain || libraries["contracts/Lib.sol:Lib"] != testLibraryAddress {
+		t.Errorf("unexpected link %s %v", linked, libraries)
+	}
+
+	// without linkReferences the placeholder is found by scanning and named from settings.libraries
+	linked, libraries = linkLibraries(compiled, chain, nil, map[string]string{"contracts/Lib.sol:Lib": strings.ToUpper(address)})
+	if linked != chain || !reflect.DeepEqual(libraries, map[string]string{"contracts/Lib.sol:Lib": testLibraryAddress}) {
+		t.Errorf("unexpected link %s %v", linked, libraries)
+	}
+
+	// pre 0.5 placeholders carry the library name
+	old := "73__Lib___________________________________5af4"
+	linked, libraries = linkLibraries(old, chain, nil, nil)
+	if linked != chain || libraries["Lib"] != testLibraryAddress {
+		t.Errorf("unexpected link %s %v", linked, libraries)
+	}
+
+	// on-chain code shorter than the template leaves the placeholder instead of panicking
+	linked, libraries = linkLibraries(compiled, "73", references, nil)
+	if linked != compiled || len(libraries) != 0 {
+		t.Errorf("unexpected link %s %v", linked, libraries)
+	}
+}
+
+func Test_libraryAddresses(t *testing.T) {
+	flat := map[string]string{"contracts/Lib.sol:Lib": testLibraryAddress}
+	metadataForm := map[string]interface{}{"contracts/Lib.sol:Lib": testLibraryAddress}
+	standardForm := map[string]interface{}{"contracts/Lib.sol": map[string]interface{}{"Lib": testLibraryAddress}}
+
+	if result := libraryAddresses(metadataForm); !reflect.DeepEqual(result, flat) {
+		t.Errorf("libraryAddresses(metadata) = %v", result)
+	}
+	if result := libraryAddresses(standardForm); !reflect.DeepEqual(result, flat) {
+		t.Errorf("libraryAddresses(standard json) = %v", result)
+	}
+	if result := nestedLibraries(flat); !reflect.DeepEqual(result, standardForm) {
+		t.Errorf("nestedLibraries = %v", result)
+	}
+}
@@ -11,6 +11,15 @@ import (
 func init() {
 	fetchChainInfo()
 	SolcManagerInstance = NewSolcManager()
+	VyperManagerInstance = NewVyperManager(SolcManagerInstance.cacheDir)
+	store, err := NewFileStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ContractStoreInstance = store
+	if SourceStoreInstance, err = NewSourceStoreFromEnv(); err != nil {
+		log.Fatal(err)
+	}
 	staticDir := SolcManagerInstance.cacheDir
 	if _, err := os.Stat(staticDir); os.IsNotExist(err) {
 		if err := os.Mkdir(staticDir, 0755); err != nil {
@@ -23,7 +32,18 @@ func main() {
 	args := os.Args
 
 	var server = func() {
+		JobManagerInstance = NewJobManagerFromEnv()
 		http.HandleFunc("/verify", verificationHandler)
+		http.HandleFunc("POST /verify/jobs", createJobHandler)
+		http.HandleFunc("POST /verify/artifact", artifactVerificationHandler)
+		http.HandleFunc("GET /verify/jobs/{id}", jobStatusHandler)
+		http.HandleFunc("GET /contracts/{chain}/{address}", contractHandler)
+		http.HandleFunc("GET /check-by-addresses", sourcifyCheckByAddressesHandler)
+		http.HandleFunc("GET /files/{chain}/{address}", sourcifyFilesHandler)
+		http.HandleFunc("/api", etherscanHandler)
+		http.HandleFunc("POST /disassemble", disassembleHandler)
+		http.HandleFunc("GET /rpc/health", rpcHealthHandler)
+		http.HandleFunc("GET /compilers", compilersHandler)
 		util.Logger().Info("Server started on :8081")
 		log.Fatal(http.ListenAndServe(":8081", nil))
 	}
@@ -36,6 +56,14 @@ func main() {
 				tagName = args[2]
 			}
 			download(tagName)
+		case "prefetch":
+			if err := prefetchCommand(args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		case "verify-artifact":
+			if err := verifyArtifactCommand(args[2:]); err != nil {
+				log.Fatal(err)
+			}
 		default:
 			server()
 		}
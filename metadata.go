@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"verify-golang/evm"
+	"verify-golang/util"
+)
+
+// BytecodeMetadata is the CBOR blob solc appends to the runtime code,
+// https://docs.soliditylang.org/en/latest/metadata.html#encoding-of-the-metadata-hash-in-the-bytecode
+// vyper until 0.3.9 appends one carrying only its version
+type BytecodeMetadata struct {
+	Ipfs         string `json:"ipfs,omitempty"`
+	Bzzr0        string `json:"bzzr0,omitempty"`
+	Bzzr1        string `json:"bzzr1,omitempty"`
+	Solc         string `json:"solc,omitempty"`
+	Experimental bool   `json:"experimental,omitempty"`
+	Vyper        string `json:"vyper,omitempty"`
+	// Raw is the hex CBOR blob without the two byte length suffix
+	Raw string `json:"raw"`
+}
+
+var ErrNoBytecodeMetadata = errors.New("bytecode has no CBOR metadata")
+
+// DecodeBytecodeMetadata decodes the CBOR metadata at the end of hex encoded runtime code,
+// or at the end of the static data of an EOF container
+func DecodeBytecodeMetadata(code string) (*BytecodeMetadata, error) {
+	raw, err := hex.DecodeString(util.TrimHex(code))
+	if err != nil {
+		return nil, err
+	}
+	if evm.IsEOF(raw) {
+		container, err := evm.ParseEOF(raw)
+		if err != nil {
+			return nil, err
+		}
+		_, end, ok := container.MetadataRange()
+		if !ok {
+			return nil, ErrNoBytecodeMetadata
+		}
+		raw = container.Data[:end]
+	}
+	if len(raw) < 2 {
+		return nil, ErrNoBytecodeMetadata
+	}
+	size := int(raw[len(raw)-2])<<8 | int(raw[len(raw)-1])
+	if size == 0 || size+2 > len(raw) {
+		return nil, ErrNoBytecodeMetadata
+	}
+	blob := raw[len(raw)-2-size : len(raw)-2]
+	decoded, n, err := util.DecodeCBOR(blob)
+	if err != nil || n != len(blob) {
+		return nil, ErrNoBytecodeMetadata
+	}
+	fields, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, ErrNoBytecodeMetadata
+	}
+
+	metadata := BytecodeMetadata{Raw: hex.EncodeToString(blob)}
+	for key, value := range fields {
+		switch key {
+		case "ipfs":
+			if b, ok := value.([]byte); ok {
+				metadata.Ipfs = util.Base58Encode(b)
+			}
+		case "bzzr0":
+			if b, ok := value.([]byte); ok {
+				metadata.Bzzr0 = hex.EncodeToString(b)
+			}
+		case "bzzr1":
+			if b, ok := value.([]byte); ok {
+				metadata.Bzzr1 = hex.EncodeToString(b)
+			}
+		case "solc":
+			switch v := value.(type) {
+			case []byte:
+				// release builds store major, minor and patch as three bytes
+				if len(v) == 3 {
+					metadata.Solc = fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+				}
+			case string:
+				metadata.Solc = v
+			}
+		case "experimental":
+			metadata.Experimental, _ = value.(bool)
+		case "vyper":
+			metadata.Vyper = vyperVersionOf(value)
+		}
+	}
+	return &metadata, nil
+}
+
+// MatchDetails explains a match, the runtime code agreed and whether the metadata hash
+// embedded on chain agreed with the hash of the recompiled metadata, null when it could not be computed
+type MatchDetails struct {
+	Bytecode     bool  `json:"bytecode"`
+	MetadataHash *bool `json:"metadata_hash"`
+	// HashType is ipfs, bzzr0 or bzzr1, empty when the chain carries no metadata hash
+	HashType     string `json:"hash_type,omitempty"`
+	OnchainHash  string `json:"onchain_hash,omitempty"`
+	CompiledHash string `json:"compiled_hash,omitempty"`
+}
+
+// hash returns the metadata hash type and value embedded on chain
+func (m *BytecodeMetadata) hash() (string, string) {
+	switch {
+	case m == nil:
+		return "", ""
+	case m.Ipfs != "":
+		return "ipfs", m.Ipfs
+	case m.Bzzr1 != "":
+		return "bzzr1", m.Bzzr1
+	case m.Bzzr0 != "":
+		return "bzzr0", m.Bzzr0
+	}
+	return "", ""
+}
+
+// metadataHash hashes the compiler metadata json the way solc does for the given hash type
+func metadataHash(hashType string, metadata []byte) string {
+	switch hashType {
+	case "ipfs":
+		return util.Base58Encode(util.IpfsHash(metadata))
+	case "bzzr1":
+		return hex.EncodeToString(util.Bzzr1Hash(metadata))
+	case "bzzr0":
+		return hex.EncodeToString(util.Bzzr0Hash(metadata))
+	}
+	return ""
+}
+
+// checkMetadataHash compares the on-chain metadata hash with the recompiled metadata. When the chain
+// carries a hash and the compiler returned metadata it decides the status, perfect only if the hashes
+// agree, partial otherwise. Without compiled metadata the byte comparison stands
+func (m *Match) checkMetadataHash(onchain *BytecodeMetadata, compiledMetadata any) {
+	details := MatchDetails{Bytecode: m.Status != mismatch}
+	m.Details = &details
+	hashType, onchainHash := onchain.hash()
+	if !details.Bytecode || hashType == "" {
+		return
+	}
+	details.HashType = hashType
+	details.OnchainHash = onchainHash
+	if metadata, ok := compiledMetadata.(string); ok && metadata != "" {
+		details.CompiledHash = metadataHash(hashType, []byte(metadata))
+	}
+	if details.CompiledHash == "" {
+		return
+	}
+	agreed := details.CompiledHash == onchainHash
+	details.MetadataHash = &agreed
+	if agreed {
+		m.Status = perfect
+	} else {
+		m.Status = partial
+	}
+}
+
+// shortVersion turns "v0.8.19+commit.7dd6d404" into "0.8.19"
+func shortVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	if index := strings.Index(version, "+"); index >= 0 {
+		version = version[:index]
+	}
+	return version
+}
+
+// checkCompilerVersion defaults CompilerVersion to the solc version embedded in the on-chain code,
+// or rejects a request whose version disagrees with it
+func (v *VerificationRequest) checkCompilerVersion(metadata *BytecodeMetadata) error {
+	if metadata != nil && metadata.Vyper != "" {
+		return v.checkVyperVersion(metadata.Vyper)
+	}
+	if metadata == nil || metadata.Solc == "" {
+		if v.CompilerVersion == "" {
+			return ErrCompilerVersionRequired
+		}
+		return nil
+	}
+	if v.CompilerVersion == "" {
+		version, err := SolcManagerInstance.ResolveVersion(shortVersion(metadata.Solc))
+		if err != nil {
+			return err
+		}
+		util.Logger().Info(fmt.Sprintf("detected compiler version %s for contract %s", version, v.Address))
+		v.CompilerVersion = version
+		return nil
+	}
+	if chainVersion, sent := shortVersion(metadata.Solc), shortVersion(v.CompilerVersion); chainVersion != sent {
+		return fmt.Errorf("compiler version mismatch: chain says %s, you sent %s", chainVersion, sent)
+	}
+	return nil
+}
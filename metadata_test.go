@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testIpfsMetadata = "a26469706673582212208c0f254fc3c3b217e54226bdd6f2f788a6b770c6a3c09ea3501cfe671333744564736f6c6343000813"
+
+func TestDecodeBytecodeMetadata(t *testing.T) {
+	metadata, err := DecodeBytecodeMetadata("0x6080604052600080fd" + testIpfsMetadata + "0033")
+	if err != nil {
+		t.Fatalf("DecodeBytecodeMetadata failed: %v", err)
+	}
+	if metadata.Ipfs != "QmXmPBXBFoG9gcUf3MHA7KmBdJS4AkHXeXVM6pHimbSozU" || metadata.Solc != "0.8.19" || metadata.Raw != testIpfsMetadata {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	// bytecodeHash none, only the compiler version is embedded
+	metadata, err = DecodeBytecodeMetadata("6080604052348015600f57600080fd5bfea164736f6c6343000811000a")
+	if err != nil {
+		t.Fatalf("DecodeBytecodeMetadata failed: %v", err)
+	}
+	if metadata.Solc != "0.8.17" || metadata.Ipfs != "" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	// EOF keeps the metadata at the end of the static data, aux data may follow it
+	metadata, err = DecodeBytecodeMetadata(testEOFContainer("00", testIpfsMetadata+"0033"+"1111", 55))
+	if err != nil {
+		t.Fatalf("DecodeBytecodeMetadata failed: %v", err)
+	}
+	if metadata.Solc != "0.8.19" || metadata.Raw != testIpfsMetadata {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	for _, code := range []string{"", "0x", "6080604052", "60806040520005", testEOFContainer("00", "aabb", 2)} {
+		if _, err = DecodeBytecodeMetadata(code); err == nil {
+			t.Errorf("DecodeBytecodeMetadata(%s) expected error", code)
+		}
+	}
+}
+
+func Test_checkCompilerVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"releases": {"0.8.19": "solc-` + solcPlatform() + `-v0.8.19+commit.7dd6d404"}}`))
+	}))
+	defer ts.Close()
+	previous := SolcManagerInstance
+	SolcManagerInstance = &SolcManager{cacheDir: t.TempDir(), listURL: ts.URL}
+	defer func() { SolcManagerInstance = previous }()
+
+	onchain := &BytecodeMetadata{Solc: "0.8.19"}
+
+	req := VerificationRequest{}
+	if err := req.checkCompilerVersion(onchain); err != nil || req.CompilerVersion != "v0.8.19+commit.7dd6d404" {
+		t.Errorf("version not detected: %v %s", err, req.CompilerVersion)
+	}
+
+	req = VerificationRequest{CompilerVersion: "v0.8.20+commit.a1b79de6"}
+	err := req.checkCompilerVersion(onchain)
+	if err == nil || err.Error() != "compiler version mismatch: chain says 0.8.19, you sent 0.8.20" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	req = VerificationRequest{}
+	if err = req.checkCompilerVersion(nil); err != ErrCompilerVersionRequired {
+		t.Errorf("expected ErrCompilerVersionRequired, got %v", err)
+	}
+}
+
+func Test_metadataHash(t *testing.T) {
+	data, err := os.ReadFile("static/example_metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata SolcMetadata
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	// solc hashes sources the same way it hashes metadata, the urls carry the expected values
+	content := []byte(metadata.Sources["contracts/new.sol"].Content)
+	if hash := metadataHash("ipfs", content); hash != "QmXmPBXBFoG9gcUf3MHA7KmBdJS4AkHXeXVM6pHimbSozU" {
+		t.Errorf("ipfs hash %s", hash)
+	}
+	if hash := metadataHash("bzzr1", content); hash != "c88f49b388be179db5b7f5997f61e66a0689f17502cf3df4c71130549d835409" {
+		t.Errorf("bzzr1 hash %s", hash)
+	}
+	if hash := metadataHash("", content); hash != "" {
+		t.Errorf("unknown hash type returned %s", hash)
+	}
+}
+
+func TestMatch_checkMetadataHash(t *testing.T) {
+	compiled := `{"compiler":{"version":"0.8.19+commit.7dd6d404"}}`
+	onchain := &BytecodeMetadata{Ipfs: metadataHash("ipfs", []byte(compiled)), Solc: "0.8.19"}
+
+	match := &Match{Status: partial}
+	match.checkMetadataHash(onchain, compiled)
+	if match.Status != perfect || !match.Details.Bytecode || match.Details.MetadataHash == nil || !*match.Details.MetadataHash || match.Details.HashType != "ipfs" {
+		t.Errorf("expected a perfect match, got %s %+v", match.Status, match.Details)
+	}
+
+	// only the metadata differs
+	match = &Match{Status: perfect}
+	match.checkMetadataHash(onchain, `{"compiler":{"version":"0.8.19+commit.7dd6d404"},"settings":{}}`)
+	if match.Status != partial || match.Details.MetadataHash == nil || *match.Details.MetadataHash || match.Details.CompiledHash == match.Details.OnchainHash {
+		t.Errorf("expected a partial match, got %s %+v", match.Status, match.Details)
+	}
+
+	// without compiled metadata the hash is unknown and the byte comparison stands
+	for _, status := range []string{perfect, partial} {
+		match = &Match{Status: status}
+		match.checkMetadataHash(onchain, nil)
+		if match.Status != status || match.Details.MetadataHash != nil || match.Details.HashType != "ipfs" {
+			t.Errorf("expected the %s status to be kept, got %s %+v", status, match.Status, match.Details)
+		}
+	}
+
+	// bytecodeHash none leaves the byte comparison in charge
+	match = &Match{Status: perfect}
+	match.checkMetadataHash(&BytecodeMetadata{Solc: "0.8.19"}, compiled)
+	if match.Status != perfect || match.Details.HashType != "" {
+		t.Errorf("expected the status to be kept, got %s %+v", match.Status, match.Details)
+	}
+}
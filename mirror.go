@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"verify-golang/util"
+)
+
+// Compilers come from GitHub unless a mirror is configured. SOLC_MIRROR is a solc-bin copy, with a directory
+// per platform, and REVIVE_MIRROR holds resolc releases as <tag>/<asset>; both are a local directory or an
+// http(s) url. With VERIFY_OFFLINE nothing is fetched over the network, only the static cache and local
+// mirrors are used.
+
+var ErrOffline = errors.New("VERIFY_OFFLINE is set")
+
+const cachedListName = "list.json"
+
+func offline() bool {
+	enabled, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("VERIFY_OFFLINE")))
+	return enabled
+}
+
+func isRemote(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func joinLocation(base, name string) string {
+	return strings.TrimSuffix(base, "/") + "/" + name
+}
+
+// openLocation opens an http(s) url, refused in offline mode, or a local path. The size is -1 when unknown
+func openLocation(location string) (io.ReadCloser, int64, error) {
+	if !isRemote(location) {
+		f, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, -1, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, -1, err
+		}
+		return f, info.Size(), nil
+	}
+	if offline() {
+		return nil, -1, fmt.Errorf("%w: %s not fetched", ErrOffline, location)
+	}
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, -1, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, -1, fmt.Errorf("fetch %s failed: %d", location, resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// solcRepoFromEnv is the solc-bin directory of this platform, on SOLC_MIRROR when set
+func solcRepoFromEnv() string {
+	if mirror := strings.TrimSpace(os.Getenv("SOLC_MIRROR")); mirror != "" {
+		return joinLocation(mirror, solcPlatform()) + "/"
+	}
+	return solcRepo()
+}
+
+// releasesBetween lists the long versions of the releases from one version to another, both included, oldest first
+func (sm *SolcManager) releasesBetween(from, to string) ([]string, error) {
+	list, err := sm.fetchList(false)
+	if err != nil {
+		return nil, err
+	}
+	var releases []string
+	for release := range list.Releases {
+		if compareVersions(from, release) <= 0 && compareVersions(release, to) <= 0 {
+			releases = append(releases, release)
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return compareVersions(releases[i], releases[j]) < 0 })
+	versions := make([]string, 0, len(releases))
+	for _, release := range releases {
+		versions = append(versions, strings.TrimPrefix(list.Releases[release], fmt.Sprintf("solc-%s-", solcPlatform())))
+	}
+	return versions, nil
+}
+
+// saveList keeps list.json in the static cache, where offline mode reads it
+func (sm *SolcManager) saveList() error {
+	list, err := sm.fetchList(false)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(sm.cacheDir, ".tmp-list-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(sm.cacheDir, cachedListName))
+}
+
+// prefetchCommand fills the static cache with every solc release from args[0] to args[1], or only args[0],
+// together with list.json so that an offline server still resolves versions
+func prefetchCommand(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: prefetch <from version> [to version]")
+	}
+	from, to := args[0], args[len(args)-1]
+	versions, err := SolcManagerInstance.releasesBetween(from, to)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no solc release from %s to %s", from, to)
+	}
+	for _, version := range versions {
+		if err = SolcManagerInstance.EnsureVersion(version); err != nil {
+			return fmt.Errorf("prefetch solc %s: %w", version, err)
+		}
+		util.Logger().Info(fmt.Sprintf("solc %s cached", version))
+	}
+	return SolcManagerInstance.saveList()
+}
+
+// downloadMirrorResolc copies a resolc release from REVIVE_MIRROR to the working directory, without a tag the
+// release named in the mirror latest file
+func downloadMirrorResolc(mirror, tag string) (string, string) {
+	if tag == "" {
+		latest, _, err := openLocation(joinLocation(mirror, "latest"))
+		if err != nil {
+			panic(err)
+		}
+		data, err := io.ReadAll(latest)
+		latest.Close()
+		if err != nil {
+			panic(err)
+		}
+		tag = strings.TrimSpace(string(data))
+	}
+	for _, fileName := range resolcFileNames() {
+		asset, _, err := openLocation(joinLocation(joinLocation(mirror, tag), fileName))
+		if err != nil {
+			continue
+		}
+		defer asset.Close()
+		outFile, err := os.Create(fileName)
+		if err != nil {
+			panic(err)
+		}
+		defer outFile.Close()
+		if _, err = io.Copy(outFile, asset); err != nil {
+			panic(err)
+		}
+		util.Logger().Info(fmt.Sprintf("resolc %s copied from %s", tag, mirror))
+		return fileName, tag
+	}
+	panic(fmt.Sprintf("resolc %s not found in %s", tag, mirror))
+}
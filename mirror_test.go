@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"verify-golang/util"
+)
+
+// solcMirrorDir lays out a solc-bin directory with a binary for each release, release -> long version
+func solcMirrorDir(t *testing.T, releases map[string]string) string {
+	t.Helper()
+	mirror := t.TempDir()
+	platformDir := filepath.Join(mirror, solcPlatform())
+	if err := os.MkdirAll(platformDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	list := solcList{Releases: map[string]string{}}
+	for release, version := range releases {
+		path := "solc-" + solcPlatform() + "-" + version
+		binary := []byte("#!/bin/sh\necho " + version + "\n")
+		if err := os.WriteFile(filepath.Join(platformDir, path), binary, 0644); err != nil {
+			t.Fatal(err)
+		}
+		sha := sha256.Sum256(binary)
+		list.Builds = append(list.Builds, solcBuild{Path: path, Sha256: "0x" + hex.EncodeToString(sha[:]), Keccak256: util.Keccak256Hex(binary)})
+		list.Releases[release] = path
+	}
+	data, _ := json.Marshal(list)
+	if err := os.WriteFile(filepath.Join(platformDir, "list.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mirror
+}
+
+func Test_openLocation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.json")
+	_ = os.WriteFile(file, []byte("{}"), 0644)
+	for _, location := range []string{file, "file://" + file} {
+		body, size, err := openLocation(location)
+		if err != nil || size != 2 {
+			t.Fatalf("openLocation(%s) failed: %v", location, err)
+		}
+		body.Close()
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	if _, _, err := openLocation(server.URL + "/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404, got %v", err)
+	}
+	t.Setenv("VERIFY_OFFLINE", "true")
+	if _, _, err := openLocation(server.URL + "/missing"); !errors.Is(err, ErrOffline) || requests.Load() != 1 {
+		t.Errorf("expected ErrOffline without a request, got %v", err)
+	}
+}
+
+func TestSolcManagerMirror(t *testing.T) {
+	mirror := solcMirrorDir(t, map[string]string{"0.8.19": testSolcVersion})
+	t.Setenv("SOLC_MIRROR", mirror)
+	// a local mirror still works offline
+	t.Setenv("VERIFY_OFFLINE", "1")
+	sm := NewSolcManager()
+	sm.cacheDir = t.TempDir()
+	if sm.repoURL != mirror+"/"+solcPlatform()+"/" {
+		t.Errorf("unexpected repo %s", sm.repoURL)
+	}
+	if err := sm.EnsureVersion(testSolcVersion); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(sm.cacheDir, testSolcVersion)); err != nil {
+		t.Errorf("solc not cached: %v", err)
+	}
+}
+
+func TestSolcManagerOffline(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+	t.Setenv("VERIFY_OFFLINE", "true")
+	sm := &SolcManager{cacheDir: t.TempDir(), listURL: server.URL + "/list.json", repoURL: server.URL + "/"}
+
+	err := sm.EnsureVersion(testSolcVersion)
+	if !errors.Is(err, ErrOffline) || !strings.Contains(err.Error(), "prefetch") {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+	if _, err = sm.ResolveVersion("0.8.19"); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+	// a cached binary needs no list
+	if err = os.WriteFile(filepath.Join(sm.cacheDir, testSolcVersion), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = sm.EnsureVersion(testSolcVersion); err != nil {
+		t.Errorf("cached version failed offline: %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("%d requests made offline", requests.Load())
+	}
+
+	vm := NewVyperManager(t.TempDir())
+	if err = vm.EnsureVersion("0.3.10"); !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline, got %v", err)
+	}
+}
+
+func Test_prefetchCommand(t *testing.T) {
+	mirror := solcMirrorDir(t, map[string]string{
+		"0.8.18": "v0.8.18+commit.87f61d96",
+		"0.8.19": testSolcVersion,
+		"0.8.20": "v0.8.20+commit.a1b79de6",
+	})
+	previous := SolcManagerInstance
+	defer func() { SolcManagerInstance = previous }()
+	repo := mirror + "/" + solcPlatform() + "/"
+	SolcManagerInstance = &SolcManager{cacheDir: t.TempDir(), listURL: repo + "list.json", repoURL: repo}
+
+	if err := prefetchCommand([]string{"0.8.19", "0.8.20"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(SolcManagerInstance.cacheDir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "list.json,v0.8.19+commit.7dd6d404,v0.8.20+commit.a1b79de6" {
+		t.Errorf("unexpected cache %v", names)
+	}
+	if err := prefetchCommand([]string{"0.9.0"}); err == nil {
+		t.Errorf("expected no release to prefetch")
+	}
+
+	// offline the cached list resolves versions
+	t.Setenv("VERIFY_OFFLINE", "true")
+	sm := &SolcManager{cacheDir: SolcManagerInstance.cacheDir, listURL: "https://example.invalid/list.json", repoURL: "https://example.invalid/"}
+	if version, err := sm.ResolveVersion("0.8.20"); err != nil || version != "v0.8.20+commit.a1b79de6" {
+		t.Errorf("ResolveVersion() = %s, %v", version, err)
+	}
+	if err := sm.EnsureVersion(testSolcVersion); err != nil {
+		t.Errorf("prefetched version failed offline: %v", err)
+	}
+}
+
+func Test_downloadMirrorResolc(t *testing.T) {
+	mirror := t.TempDir()
+	fileName := resolcFileNames()[0]
+	if err := os.MkdirAll(filepath.Join(mirror, "v0.1.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filepath.Join(mirror, "v0.1.0", fileName), []byte("resolc"), 0644)
+	_ = os.WriteFile(filepath.Join(mirror, "latest"), []byte("v0.1.0\n"), 0644)
+	// the asset is copied to the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd) // nolint: errcheck
+
+	name, tag := downloadMirrorResolc(mirror, "")
+	if name != fileName || tag != "v0.1.0" {
+		t.Errorf("downloadMirrorResolc() = %s, %s", name, tag)
+	}
+	if content, _ := os.ReadFile(fileName); string(content) != "resolc" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
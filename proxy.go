@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"verify-golang/evm"
+	"verify-golang/util"
+)
+
+// Proxy types reported in ProxyInfo
+const (
+	proxyMinimal     = "eip1167"
+	proxyMinimalPush = "eip7511"
+	proxyEIP1967     = "eip1967"
+	proxyTransparent = "transparent"
+	proxyBeacon      = "beacon"
+	proxyEIP1822     = "eip1822"
+	proxyZeppelinOS  = "zeppelinos"
+)
+
+// Storage slots of the proxy standards, https://eips.ethereum.org/EIPS/eip-1967
+const (
+	// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+	slotEIP1967Implementation = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+	// bytes32(uint256(keccak256("eip1967.proxy.beacon")) - 1)
+	slotEIP1967Beacon = "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50"
+	// bytes32(uint256(keccak256("eip1967.proxy.admin")) - 1)
+	slotEIP1967Admin = "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103"
+	// keccak256("PROXIABLE"), EIP-1822
+	slotEIP1822Proxiable = "0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7"
+	// keccak256("org.zeppelinos.proxy.implementation"), OpenZeppelin proxies before EIP-1967
+	slotZeppelinOSImplementation = "0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3"
+
+	// implementation() of an EIP-1967 beacon
+	selectorImplementation = "0x5c60da1b"
+)
+
+// ProxyInfo describes the proxy found at Address
+type ProxyInfo struct {
+	Type           string `json:"type"`
+	Address        string `json:"address"`
+	Implementation string `json:"implementation,omitempty"`
+	Beacon         string `json:"beacon,omitempty"`
+	Admin          string `json:"admin,omitempty"`
+	// Resolved tells that the implementation was compared instead of the proxy
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// minimal tells the standard clone bytecodes, which are not compiled from any source
+func (p *ProxyInfo) minimal() bool {
+	return p.Type == proxyMinimal || p.Type == proxyMinimalPush
+}
+
+// EIP-1167 clone, 363d3d373d3d3d363d73<address>5af43d82803e903d91602b57fd5bf3. Vanity addresses
+// with leading zero bytes are pushed with a shorter PUSH, which moves the jump target
+var minimalProxyPatterns = []struct {
+	kind, prefix, suffix string
+}{
+	{proxyMinimal, "363d3d373d3d3d363d", "5af43d82803e903d91"},
+	// ERC-7511, the same clone using PUSH0
+	{proxyMinimalPush, "365f5f375f5f365f", "5af43d5f5f3e5f3d91"},
+}
+
+// matchMinimalProxy returns the type and implementation of a minimal proxy runtime code, hex without 0x
+func matchMinimalProxy(code string) (string, string) {
+	code = strings.ToLower(code)
+	for _, pattern := range minimalProxyPatterns {
+		rest, ok := strings.CutPrefix(code, pattern.prefix)
+		if !ok || len(rest) < 2 {
+			continue
+		}
+		op := evm.OpCode(decodeCode(rest[:2])[0])
+		if !op.IsPush() {
+			continue
+		}
+		size := int(op-evm.PUSH1) + 1
+		if size > 20 || len(rest) < 2+size*2 {
+			continue
+		}
+		address, rest := rest[2:2+size*2], rest[2+size*2:]
+		// the jump target after the suffix depends on the push size
+		tail, ok := strings.CutPrefix(rest, pattern.suffix)
+		if !ok || len(tail) != 12 || !strings.HasPrefix(tail, "60") || tail[4:] != "57fd5bf3" {
+			continue
+		}
+		return pattern.kind, util.ChecksumAddress("0x" + strings.Repeat("00", 20-size) + address)
+	}
+	return "", ""
+}
+
+// slotAddress reads an address stored in a storage slot, empty when the slot is zero
+func slotAddress(word string) string {
+	word = util.TrimHex(word)
+	if len(word) < 40 || strings.Trim(word, "0") == "" {
+		return ""
+	}
+	return util.ChecksumAddress("0x" + word[len(word)-40:])
+}
+
+// detectProxy recognises minimal proxies by their code and storage proxies by the slots they keep
+// the implementation in. Only code using DELEGATECALL is looked at, nil when it is not a proxy
+func (v *VerificationRequest) detectProxy(ctx context.Context, chainBytecode string) (*ProxyInfo, error) {
+	code := util.TrimHex(chainBytecode)
+	if kind, implementation := matchMinimalProxy(code); kind != "" {
+		return &ProxyInfo{Type: kind, Address: v.Address, Implementation: implementation}, nil
+	}
+	raw, err := hex.DecodeString(code)
+	if err != nil || !evm.Uses(raw, evm.DELEGATECALL) {
+		return nil, nil
+	}
+
+	client, err := rpcClientOf(v.Chain)
+	if err != nil {
+		return nil, err
+	}
+	block, err := v.blockParam()
+	if err != nil {
+		return nil, err
+	}
+	readSlot := func(slot string) (string, error) {
+		var word string
+		if err := client.Call(ctx, "eth_getStorageAt", []any{v.Address, slot, block}, &word); err != nil {
+			return "", err
+		}
+		return slotAddress(word), nil
+	}
+
+	implementation, err := readSlot(slotEIP1967Implementation)
+	if err != nil {
+		return nil, err
+	}
+	if implementation != "" {
+		proxy := &ProxyInfo{Type: proxyEIP1967, Address: v.Address, Implementation: implementation}
+		if proxy.Admin, err = readSlot(slotEIP1967Admin); err != nil {
+			return nil, err
+		}
+		if proxy.Admin != "" {
+			proxy.Type = proxyTransparent
+		}
+		return proxy, nil
+	}
+
+	beacon, err := readSlot(slotEIP1967Beacon)
+	if err != nil {
+		return nil, err
+	}
+	if beacon != "" {
+		var word string
+		if err = client.Call(ctx, "eth_call", []any{map[string]string{"to": beacon, "data": selectorImplementation}, block}, &word); err != nil {
+			return nil, fmt.Errorf("read beacon implementation: %w", err)
+		}
+		return &ProxyInfo{Type: proxyBeacon, Address: v.Address, Beacon: beacon, Implementation: slotAddress(word)}, nil
+	}
+
+	for _, slot := range []struct{ kind, slot string }{{proxyEIP1822, slotEIP1822Proxiable}, {proxyZeppelinOS, slotZeppelinOSImplementation}} {
+		if implementation, err = readSlot(slot.slot); err != nil {
+			return nil, err
+		}
+		if implementation != "" {
+			return &ProxyInfo{Type: slot.kind, Address: v.Address, Implementation: implementation}, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveProxy detects a proxy at the requested address. Minimal proxies, and other proxies when
+// VerifyImplementation is set, are verified through their implementation: the request keeps its
+// address, records the implementation and its code is returned instead
+func (v *VerificationRequest) resolveProxy(ctx context.Context, chainBytecode string) (string, *ProxyInfo, error) {
+	proxy, err := v.detectProxy(ctx, chainBytecode)
+	if err != nil {
+		// detection is best effort, the proxy code itself can still be verified
+		util.Logger().Error(fmt.Errorf("detect proxy of contract %s failed: %v", v.Address, err))
+		return chainBytecode, nil, nil
+	}
+	if proxy == nil || proxy.Implementation == "" || !(v.VerifyImplementation || proxy.minimal()) {
+		return chainBytecode, proxy, nil
+	}
+
+	util.Logger().Info(fmt.Sprintf("contract %s is a %s proxy, verify implementation %s", v.Address, proxy.Type, proxy.Implementation))
+	v.implementation = proxy.Implementation
+	proxy.Resolved = true
+	if chainBytecode, err = v.fetchChainBytecode(ctx); err != nil {
+		return "", nil, err
+	}
+	if chainBytecode == "" {
+		return "", nil, ErrBytecodeNotFound
+	}
+	return chainBytecode, proxy, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"verify-golang/util"
+)
+
+const (
+	testProxyAddress          = "0x00000000001523057a05d6293c1e5171ee33ee0a"
+	testImplementationAddress = "0xBEbeBeBEbeBebeBeBEBEbebEBeBeBebeBeBebebe"
+	// calldatacopy then delegatecall to the implementation in slot 0
+	testDelegatingCode = "0x363d3d37600054" + "5af4"
+)
+
+// proxyTestChain registers a chain whose rpc answers from storage, code by address and a beacon
+func proxyTestChain(t *testing.T, storage map[string]string, code map[string]string) int64 {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var first, second string
+		_ = json.Unmarshal(req.Params[0], &first)
+		if len(req.Params) > 1 {
+			_ = json.Unmarshal(req.Params[1], &second)
+		}
+		result := "0x"
+		switch req.Method {
+		case "eth_getStorageAt":
+			result = "0x" + strings.Repeat("0", 64)
+			if value, ok := storage[second]; ok {
+				result = value
+			}
+		case "eth_getCode":
+			result = code[strings.ToLower(first)]
+		case "eth_call":
+			result = "0x000000000000000000000000" + strings.ToLower(testImplementationAddress[2:])
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	return registerTestChain(t, ChainInfo{Rpc: []string{server.URL}})
+}
+
+func slotWord(address string) string {
+	return "0x000000000000000000000000" + strings.ToLower(address[2:])
+}
+
+func Test_matchMinimalProxy(t *testing.T) {
+	tests := []struct {
+		name           string
+		code           string
+		kind           string
+		implementation string
+	}{
+		{"eip1167", "363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3", proxyMinimal, testImplementationAddress},
+		{"eip7511", "365f5f375f5f365f73bebebebebebebebebebebebebebebebebebebebe5af43d5f5f3e5f3d91602a57fd5bf3", proxyMinimalPush, testImplementationAddress},
+		{"vanity address", "363d3d373d3d3d363d6fbebebebebebebebebebebebebebebebe5af43d82803e903d91602757fd5bf3", proxyMinimal, "0x00000000bEbEbebEbEBEbEbebeBEbebebebEbeBE"},
+		{"extra code", "363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf300", "", ""},
+		{"not a proxy", "6080604052600080fd", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, implementation := matchMinimalProxy(tt.code)
+			if kind != tt.kind || !strings.EqualFold(implementation, tt.implementation) {
+				t.Errorf("matchMinimalProxy() = %s, %s; want %s, %s", kind, implementation, tt.kind, tt.implementation)
+			}
+		})
+	}
+}
+
+func Test_detectProxy(t *testing.T) {
+	admin := "0x00000000000000000000000000000000000000aa"
+	beacon := "0x00000000000000000000000000000000000000bb"
+	tests := []struct {
+		name    string
+		code    string
+		storage map[string]string
+		want    *ProxyInfo
+	}{
+		{"uups", testDelegatingCode, map[string]string{slotEIP1967Implementation: slotWord(testImplementationAddress)},
+			&ProxyInfo{Type: proxyEIP1967, Implementation: testImplementationAddress}},
+		{"transparent", testDelegatingCode, map[string]string{slotEIP1967Implementation: slotWord(testImplementationAddress), slotEIP1967Admin: slotWord(admin)},
+			&ProxyInfo{Type: proxyTransparent, Implementation: testImplementationAddress, Admin: util.ChecksumAddress(admin)}},
+		{"beacon", testDelegatingCode, map[string]string{slotEIP1967Beacon: slotWord(beacon)},
+			&ProxyInfo{Type: proxyBeacon, Implementation: testImplementationAddress, Beacon: util.ChecksumAddress(beacon)}},
+		{"eip1822", testDelegatingCode, map[string]string{slotEIP1822Proxiable: slotWord(testImplementationAddress)},
+			&ProxyInfo{Type: proxyEIP1822, Implementation: testImplementationAddress}},
+		{"zeppelinos", testDelegatingCode, map[string]string{slotZeppelinOSImplementation: slotWord(testImplementationAddress)},
+			&ProxyInfo{Type: proxyZeppelinOS, Implementation: testImplementationAddress}},
+		{"delegatecall without slots", testDelegatingCode, nil, nil},
+		// storage is not read without a DELEGATECALL
+		{"no delegatecall", "0x6080604052600080fd", map[string]string{slotEIP1967Implementation: slotWord(testImplementationAddress)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := VerificationRequest{Chain: proxyTestChain(t, tt.storage, nil), Address: testProxyAddress}
+			proxy, err := req.detectProxy(context.Background(), tt.code)
+			if err != nil {
+				t.Fatalf("detectProxy failed: %v", err)
+			}
+			if tt.want == nil {
+				if proxy != nil {
+					t.Errorf("unexpected proxy %+v", proxy)
+				}
+				return
+			}
+			tt.want.Address = testProxyAddress
+			if proxy == nil || *proxy != *tt.want {
+				t.Errorf("detectProxy() = %+v; want %+v", proxy, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveProxy(t *testing.T) {
+	storage := map[string]string{slotEIP1967Implementation: slotWord(testImplementationAddress)}
+	code := map[string]string{strings.ToLower(testImplementationAddress): "0x6080604052600080fd"}
+	chain := proxyTestChain(t, storage, code)
+
+	// without verifyImplementation the proxy is only reported
+	req := VerificationRequest{Chain: chain, Address: testProxyAddress}
+	chainBytecode, proxy, err := req.resolveProxy(context.Background(), testDelegatingCode)
+	if err != nil || chainBytecode != testDelegatingCode || proxy == nil || proxy.Resolved || req.Address != testProxyAddress {
+		t.Fatalf("resolveProxy() = %s, %+v, %v", chainBytecode, proxy, err)
+	}
+	mismatchErr := &MismatchError{Proxy: proxy}
+	if !strings.Contains(mismatchErr.Error(), "set verifyImplementation") {
+		t.Errorf("unexpected message %s", mismatchErr.Error())
+	}
+
+	req.VerifyImplementation = true
+	chainBytecode, proxy, err = req.resolveProxy(context.Background(), testDelegatingCode)
+	if err != nil || chainBytecode != "0x6080604052600080fd" || !proxy.Resolved || req.Address != testProxyAddress || req.codeAddress() != testImplementationAddress {
+		t.Fatalf("resolveProxy() = %s, %+v, %v", chainBytecode, proxy, err)
+	}
+	if resp := errorResponse(&MismatchError{Proxy: proxy}); resp.Message != "bytecode mismatch" || resp.Proxy != proxy {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// minimal proxies are always followed
+	req = VerificationRequest{Chain: chain, Address: testProxyAddress}
+	chainBytecode, proxy, err = req.resolveProxy(context.Background(), "0x363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3")
+	if err != nil || chainBytecode != "0x6080604052600080fd" || proxy.Type != proxyMinimal || !proxy.Resolved {
+		t.Errorf("resolveProxy() = %s, %+v, %v", chainBytecode, proxy, err)
+	}
+}
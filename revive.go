@@ -23,18 +23,19 @@ type ReviveMetadata struct {
 	SolcMetadata
 }
 
-func (s *ReviveMetadata) recompileContract(_ context.Context, version string) (*SolcOutput, error) {
+func (s *ReviveMetadata) recompileContract(ctx context.Context, version string) (*SolcOutput, error) {
 	//  ./resolc --solc ./v0.8.17+commit.8df45f5f  --standard-json<example_input.json
 	solcPath := filepath.Join(SolcManagerInstance.cacheDir, "resolc")
 	if s.ResolcVersion != "" {
 		solcPath = filepath.Join(SolcManagerInstance.cacheDir, s.ResolcVersion)
 	}
-	cmd := exec.Command(solcPath, "--solc", filepath.Join(SolcManagerInstance.cacheDir, version), "--standard-json")
+	cmd := exec.CommandContext(ctx, solcPath, "--solc", filepath.Join(SolcManagerInstance.cacheDir, version), "--standard-json")
 	var stdoutBuf, stderrBuf bytes.Buffer
 	cmd.Stdout = &stdoutBuf
 	cmd.Stderr = &stderrBuf
 	var result SolcOutput
 	result.CompileTarget, result.ContractName = s.PickComplicationTarget()
+	result.Libraries = libraryAddresses(s.Settings.Libraries)
 	stdinPipe, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("create pipe fail: %v", err)
@@ -71,12 +72,20 @@ type Asset struct {
 
 func download(tag string) {
 	util.Logger().Info("Start downloading latest resolc binary")
-	const repo = "paritytech/revive"
-	var apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
-	if tag != "" {
-		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, tag)
+	var fileName, tagName string
+	if mirror := strings.TrimSpace(os.Getenv("REVIVE_MIRROR")); mirror != "" {
+		fileName, tagName = downloadMirrorResolc(mirror, tag)
+	} else {
+		if offline() {
+			log.Fatal(fmt.Errorf("%w: set REVIVE_MIRROR to install resolc", ErrOffline))
+		}
+		const repo = "paritytech/revive"
+		var apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+		if tag != "" {
+			apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, tag)
+		}
+		fileName, tagName = downloadLatestResolc(apiURL)
 	}
-	fileName, tagName := downloadLatestResolc(apiURL)
 	var err error
 	if strings.HasSuffix(fileName, ".tar.gz") {
 		err = extractAndSetExec(fileName, "static", strings.Replace(fileName, ".tar.gz", "", 1), tagName)
@@ -93,11 +102,16 @@ func download(tag string) {
 	}
 }
 
-func downloadLatestResolc(apiURL string) (string, string) {
-	fileNames := []string{"resolc-x86_64-unknown-linux-musl", "resolc-x86_64-unknown-linux-musl.tar.gz"}
+// resolcFileNames are the release assets of this platform
+func resolcFileNames() []string {
 	if runtime.GOOS == "darwin" {
-		fileNames = []string{"resolc-universal-apple-darwin", "resolc-universal-apple-darwin.tar.gz"}
+		return []string{"resolc-universal-apple-darwin", "resolc-universal-apple-darwin.tar.gz"}
 	}
+	return []string{"resolc-x86_64-unknown-linux-musl", "resolc-x86_64-unknown-linux-musl.tar.gz"}
+}
+
+func downloadLatestResolc(apiURL string) (string, string) {
+	fileNames := resolcFileNames()
 
 	util.Logger().Info(fmt.Sprintf("Start downloading latest resolc binary %s", apiURL))
 	resp, err := http.Get(apiURL)
@@ -190,21 +204,30 @@ func extractAndSetExec(src, dest, execFile, rename string) error {
 		target := filepath.Join(dest, rename)
 		switch header.Typeflag {
 		case tar.TypeReg:
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			// extracted next to the target and renamed, a resolc being written is never picked up
+			f, err := os.CreateTemp(dest, ".tmp-*")
 			if err != nil {
 				return err
 			}
+			defer os.Remove(f.Name())
 
 			if _, err := io.Copy(f, tr); err != nil {
 				f.Close()
 				return err
 			}
-			f.Close()
+			if err := f.Close(); err != nil {
+				return err
+			}
 
+			mode := os.FileMode(header.Mode)
 			if filepath.Base(target) == execFile {
-				if err := os.Chmod(target, 0755); err != nil {
-					return err
-				}
+				mode = 0755
+			}
+			if err := os.Chmod(f.Name(), mode); err != nil {
+				return err
+			}
+			if err := os.Rename(f.Name(), target); err != nil {
+				return err
 			}
 		}
 	}
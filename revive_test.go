@@ -65,6 +65,13 @@ func Test_downloadLatestResolc(t *testing.T) {
 	})
 	defer mockServer.Close()
 
+	// the asset is downloaded to the working directory
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd) // nolint: errcheck
+
 	// Call the function with the mock server URL
 	fileName, tagName := downloadLatestResolc(mockServer.URL + "/repos/paritytech/revive/releases/latest")
 	if tagName != "v1.0.0" {
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"verify-golang/util"
+)
+
+// JSON-RPC client over every endpoint configured for a chain. Endpoints are tried healthiest first,
+// a failed round over all of them is retried after an exponential backoff.
+
+var (
+	ErrNoRpcEndpoint   = errors.New("no rpc endpoint configured")
+	ErrRpcDisagreement = errors.New("rpc endpoints returned different code")
+)
+
+// RpcError is the error object of a JSON-RPC response
+type RpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// stateUnavailableErrors are -32000 messages of nodes lacking the requested state, pruned or historical
+// blocks another endpoint such as an archive node may still serve
+var stateUnavailableErrors = []string{
+	"missing trie node",
+	"header not found",
+	"unknown block",
+	"block not found",
+	"historical state",
+	"state is not available",
+	"state not available",
+	"pruned",
+}
+
+// retryable tells errors caused by the endpoint rather than by the request, such as rate limits
+// or the state of a block it no longer keeps
+func (e *RpcError) retryable() bool {
+	switch e.Code {
+	case -32603, -32005, 429:
+		return true
+	case -32000:
+		message := strings.ToLower(e.Message)
+		for _, unavailable := range stateUnavailableErrors {
+			if strings.Contains(message, unavailable) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// historicalBlock tells a call at a past block, whose last parameter is a block number or an EIP-1898
+// block hash object, a server error there is taken for missing state as well
+func historicalBlock(params []any) bool {
+	if len(params) == 0 {
+		return false
+	}
+	switch block := params[len(params)-1].(type) {
+	case map[string]any:
+		return block["blockHash"] != nil || block["blockNumber"] != nil
+	case string:
+		return block == "earliest" || strings.HasPrefix(block, "0x") && len(block) <= 18
+	}
+	return false
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *RpcError       `json:"error"`
+}
+
+// RpcEndpointHealth is what is known of one endpoint from the calls made so far
+type RpcEndpointHealth struct {
+	Url string `json:"url"`
+	// Failures counts the consecutive failed calls, 0 when the last call succeeded
+	Failures  int       `json:"failures"`
+	Calls     int       `json:"calls"`
+	LatencyMs int64     `json:"latency_ms"`
+	LastError string    `json:"last_error,omitempty"`
+	LastCall  time.Time `json:"last_call,omitempty"`
+}
+
+type rpcEndpoint struct {
+	mu     sync.Mutex
+	health RpcEndpointHealth
+	// latency is a moving average of successful calls
+	latency time.Duration
+}
+
+func (e *rpcEndpoint) record(elapsed time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.health.Calls++
+	e.health.LastCall = time.Now()
+	if err != nil {
+		e.health.Failures++
+		e.health.LastError = err.Error()
+		return
+	}
+	e.health.Failures = 0
+	if e.latency == 0 {
+		e.latency = elapsed
+	} else {
+		e.latency = (e.latency*7 + elapsed*3) / 10
+	}
+	e.health.LatencyMs = e.latency.Milliseconds()
+}
+
+func (e *rpcEndpoint) snapshot() RpcEndpointHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.health
+}
+
+type RpcClient struct {
+	endpoints []*rpcEndpoint
+	// CrossCheck asks a second endpoint for the same code before trusting it
+	CrossCheck bool
+	Retries    int
+	Backoff    time.Duration
+	httpClient *http.Client
+}
+
+func NewRpcClient(urls []string, crossCheck bool) *RpcClient {
+	client := &RpcClient{
+		CrossCheck: crossCheck,
+		Retries:    util.EnvInt("VERIFY_RPC_RETRIES", 3),
+		Backoff:    time.Duration(util.EnvInt("VERIFY_RPC_BACKOFF_MS", 500)) * time.Millisecond,
+		httpClient: &http.Client{Timeout: time.Duration(util.EnvInt("VERIFY_RPC_TIMEOUT", 30)) * time.Second},
+	}
+	for _, url := range urls {
+		client.endpoints = append(client.endpoints, &rpcEndpoint{health: RpcEndpointHealth{Url: url}})
+	}
+	return client
+}
+
+var (
+	rpcClients   = map[int64]*RpcClient{}
+	rpcClientsMu sync.Mutex
+)
+
+// rpcClientOf returns the client of a configured chain, shared so that endpoint health outlives a request
+func rpcClientOf(chainId int64) (*RpcClient, error) {
+	rpcClientsMu.Lock()
+	defer rpcClientsMu.Unlock()
+	if client, ok := rpcClients[chainId]; ok {
+		return client, nil
+	}
+	chain, ok := chainGroup[chainId]
+	if !ok {
+		return nil, fmt.Errorf("network %d not supported", chainId)
+	}
+	if len(chain.Rpc) == 0 {
+		return nil, ErrNoRpcEndpoint
+	}
+	client := NewRpcClient(chain.Rpc, chain.CrossCheck)
+	rpcClients[chainId] = client
+	return client, nil
+}
+
+// Health lists the endpoints in the order the next call will try them
+func (c *RpcClient) Health() []RpcEndpointHealth {
+	var health []RpcEndpointHealth
+	for _, endpoint := range c.ordered(nil) {
+		health = append(health, endpoint.snapshot())
+	}
+	return health
+}
+
+// ordered puts endpoints with fewer consecutive failures first, then the faster ones, skip is left out
+func (c *RpcClient) ordered(skip *rpcEndpoint) []*rpcEndpoint {
+	type ranked struct {
+		endpoint *rpcEndpoint
+		health   RpcEndpointHealth
+	}
+	var list []ranked
+	for _, endpoint := range c.endpoints {
+		if endpoint != skip {
+			list = append(list, ranked{endpoint, endpoint.snapshot()})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].health.Failures != list[j].health.Failures {
+			return list[i].health.Failures < list[j].health.Failures
+		}
+		return list[i].health.LatencyMs < list[j].health.LatencyMs
+	})
+	endpoints := make([]*rpcEndpoint, len(list))
+	for i, r := range list {
+		endpoints[i] = r.endpoint
+	}
+	return endpoints
+}
+
+// Call runs method on the healthiest endpoint, rotating through the others on failure. A JSON-RPC error
+// about the request itself is returned at once as *RpcError, retrying it elsewhere would not help, while
+// one about state the endpoint does not keep is tried on the others
+func (c *RpcClient) Call(ctx context.Context, method string, params []any, result any) error {
+	_, err := c.call(ctx, method, params, result, nil)
+	return err
+}
+
+func (c *RpcClient) call(ctx context.Context, method string, params []any, result any, skip *rpcEndpoint) (*rpcEndpoint, error) {
+	endpoints := c.ordered(skip)
+	if len(endpoints) == 0 {
+		return nil, ErrNoRpcEndpoint
+	}
+	var lastErr error
+	for attempt := 0; attempt < max(c.Retries, 1); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.Backoff << (attempt - 1)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			endpoints = c.ordered(skip)
+		}
+		for _, endpoint := range endpoints {
+			start := time.Now()
+			err := c.post(ctx, endpoint.health.Url, method, params, result)
+			var rpcErr *RpcError
+			if errors.As(err, &rpcErr) && !rpcErr.retryable() && !(rpcErr.Code == -32000 && historicalBlock(params)) {
+				// the endpoint answered
+				endpoint.record(time.Since(start), nil)
+				return endpoint, err
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			endpoint.record(time.Since(start), err)
+			if err == nil {
+				return endpoint, nil
+			}
+			util.Logger().Warning(fmt.Sprintf("rpc %s on %s failed: %v", method, endpoint.health.Url, err))
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *RpcClient) post(ctx context.Context, url, method string, params []any, result any) error {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": rand.Intn(100000), "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var decoded rpcResponse
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("http status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("invalid rpc response: %v", err)
+	}
+	if decoded.Error != nil {
+		return decoded.Error
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(decoded.Result, result)
+}
+
+// GetCode reads the code at address, with CrossCheck a second endpoint must return the same code
+func (c *RpcClient) GetCode(ctx context.Context, address string, block any) (string, error) {
+	var code string
+	endpoint, err := c.call(ctx, "eth_getCode", []any{address, block}, &code, nil)
+	if err != nil || !c.CrossCheck {
+		return code, err
+	}
+	var other string
+	if _, err = c.call(ctx, "eth_getCode", []any{address, block}, &other, endpoint); err != nil {
+		return "", fmt.Errorf("cross check eth_getCode: %w", err)
+	}
+	if other != code {
+		util.Logger().Error(fmt.Errorf("eth_getCode of %s differs between rpc endpoints", address))
+		return "", ErrRpcDisagreement
+	}
+	return code, nil
+}
+
+// GET /rpc/health
+func rpcHealthHandler(w http.ResponseWriter, r *http.Request) {
+	health := map[string][]RpcEndpointHealth{}
+	rpcClientsMu.Lock()
+	for chainId, client := range rpcClients {
+		health[strconv.FormatInt(chainId, 10)] = client.Health()
+	}
+	rpcClientsMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(health)
+}
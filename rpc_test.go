@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testChainIds atomic.Int64
+
+// registerTestChain adds chain to chainGroup under an unused id, removed with its rpc client when the test ends
+func registerTestChain(t *testing.T, chain ChainInfo) int64 {
+	t.Helper()
+	fetchChainInfo()
+	chainId := 990000 + testChainIds.Add(1)
+	chainGroup[chainId] = chain
+	t.Cleanup(func() {
+		delete(chainGroup, chainId)
+		rpcClientsMu.Lock()
+		delete(rpcClients, chainId)
+		rpcClientsMu.Unlock()
+	})
+	return chainId
+}
+
+// rpcTestServer answers every call with body and counts the calls
+func rpcTestServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "eth_getCode" || len(req.Params) != 2 {
+			t.Errorf("unexpected rpc request %+v %v", req, err)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func testRpcClient(crossCheck bool, urls ...string) *RpcClient {
+	client := NewRpcClient(urls, crossCheck)
+	client.Retries, client.Backoff = 2, time.Millisecond
+	return client
+}
+
+func TestRpcClient_GetCode(t *testing.T) {
+	ctx := context.Background()
+	broken, _ := rpcTestServer(t, http.StatusBadGateway, "bad gateway")
+	good, goodCalls := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x6080"}`)
+	other, _ := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x6081"}`)
+	limited, _ := rpcTestServer(t, http.StatusTooManyRequests, `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"limit exceeded"}}`)
+	invalid, _ := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument"}}`)
+
+	client := testRpcClient(false, broken.URL, limited.URL, good.URL)
+	code, err := client.GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest")
+	if err != nil || code != "0x6080" {
+		t.Fatalf("GetCode = %s, %v", code, err)
+	}
+	health := client.Health()
+	if health[0].Url != good.URL || health[0].Failures != 0 || health[0].Calls != 1 || health[2].Failures != 1 || health[2].LastError == "" {
+		t.Errorf("unexpected health %+v", health)
+	}
+	// the healthy endpoint goes first from now on
+	goodCalls.Store(0)
+	if _, err = client.GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest"); err != nil || goodCalls.Load() != 1 || client.Health()[1].Calls != 1 {
+		t.Errorf("expected only the healthy endpoint to be called, %+v", client.Health())
+	}
+
+	// a request error is surfaced without trying the other endpoints
+	goodCalls.Store(0)
+	_, err = testRpcClient(false, invalid.URL, good.URL).GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest")
+	var rpcErr *RpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 || goodCalls.Load() != 0 {
+		t.Errorf("expected the rpc error, got %v", err)
+	}
+
+	// a node without the state of the block is passed over for the next one
+	pruned, _ := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"missing trie node 1c2f (path )"}}`)
+	reverted, _ := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution aborted"}}`)
+	for _, block := range []any{"latest", "0x10", map[string]any{"blockHash": testBlockHash}} {
+		if code, err = testRpcClient(false, pruned.URL, good.URL).GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", block); err != nil || code != "0x6080" {
+			t.Errorf("GetCode at %v = %s, %v", block, code, err)
+		}
+	}
+	if code, err = testRpcClient(false, reverted.URL, good.URL).GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "0x10"); err != nil || code != "0x6080" {
+		t.Errorf("GetCode at a past block = %s, %v", code, err)
+	}
+	goodCalls.Store(0)
+	if _, err = testRpcClient(false, reverted.URL, good.URL).GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest"); !errors.As(err, &rpcErr) || goodCalls.Load() != 0 {
+		t.Errorf("expected the rpc error at the latest block, got %v", err)
+	}
+
+	client = testRpcClient(false, broken.URL)
+	if _, err = client.GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest"); err == nil || client.Health()[0].Failures != 2 {
+		t.Errorf("expected a failure after two rounds, got %v %+v", err, client.Health())
+	}
+
+	tests := []struct {
+		name string
+		urls []string
+		err  error
+	}{
+		{"agree", []string{good.URL, broken.URL, good.URL}, nil},
+		{"disagree", []string{good.URL, other.URL}, ErrRpcDisagreement},
+		{"single endpoint", []string{good.URL}, ErrNoRpcEndpoint},
+	}
+	for _, tt := range tests {
+		t.Run("cross check "+tt.name, func(t *testing.T) {
+			code, err := testRpcClient(true, tt.urls...).GetCode(ctx, "0x00000000001523057a05d6293c1e5171ee33ee0a", "latest")
+			if !errors.Is(err, tt.err) || (tt.err == nil && code != "0x6080") {
+				t.Errorf("GetCode = %s, %v; want %v", code, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChainInfo_validate(t *testing.T) {
+	if err := (ChainInfo{Rpc: []string{"http://a"}, CrossCheck: true}).validate(); err != ErrCrossCheckEndpoints {
+		t.Errorf("expected ErrCrossCheckEndpoints, got %v", err)
+	}
+	if err := (ChainInfo{Rpc: []string{"http://a", "http://b"}, CrossCheck: true}).validate(); err != nil {
+		t.Errorf("validate failed: %v", err)
+	}
+}
+
+func Test_fetchChainBytecodeRpc(t *testing.T) {
+	good, _ := rpcTestServer(t, http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x6080"}`)
+	chainId := registerTestChain(t, ChainInfo{Rpc: []string{good.URL}})
+
+	req := VerificationRequest{Chain: chainId, Address: "0x00000000001523057a05d6293c1e5171ee33ee0a"}
+	if code, err := req.fetchChainBytecode(context.Background()); err != nil || code != "0x6080" {
+		t.Fatalf("fetchChainBytecode = %s, %v", code, err)
+	}
+
+	rr := httptest.NewRecorder()
+	rpcHealthHandler(rr, httptest.NewRequest("GET", "/rpc/health", nil))
+	var health map[string][]RpcEndpointHealth
+	key := strconv.FormatInt(chainId, 10)
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil || len(health[key]) != 1 || health[key][0].Calls != 1 {
+		t.Errorf("unexpected health %s", rr.Body.String())
+	}
+}
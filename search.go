@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"verify-golang/util"
+)
+
+// Opt-in search over compiler settings for users who no longer know the exact optimizer runs or evmVersion.
+// Candidates are ordered by how many settings they change, so the closest ones are compiled first.
+
+// SearchSettings is the compiler configuration a search candidate is built with
+type SearchSettings struct {
+	CompilerVersion string `json:"compiler_version"`
+	Optimizer       bool   `json:"optimizer"`
+	Runs            int    `json:"runs"`
+	EvmVersion      string `json:"evm_version,omitempty"`
+	ViaIR           bool   `json:"via_ir"`
+}
+
+var searchRuns = []int{200, 1, 1000, 10000, 999999, 100}
+
+// searchEvmVersions are tried with the first solc release supporting them, "" is the compiler default
+var searchEvmVersions = []struct {
+	name  string
+	since string
+}{
+	{"", "0.0.0"},
+	{"paris", "0.8.18"},
+	{"london", "0.8.7"},
+	{"shanghai", "0.8.20"},
+	{"cancun", "0.8.24"},
+	{"istanbul", "0.5.13"},
+	{"berlin", "0.8.5"},
+	{"prague", "0.8.30"},
+}
+
+// viaIR is production ready from 0.8.13
+const searchViaIRSince = "0.8.13"
+
+type searchResult struct {
+	settings SearchSettings
+	metadata string
+	output   *SolcOutput
+	match    *Match
+}
+
+func searchSettingsOf(version string, metadata *SolcMetadata) SearchSettings {
+	settings := SearchSettings{
+		CompilerVersion: version,
+		Optimizer:       metadata.Settings.Optimizer.Enabled,
+		Runs:            metadata.Settings.Optimizer.Runs,
+		EvmVersion:      metadata.Settings.EvmVersion,
+	}
+	if metadata.Settings.ViaIR != nil {
+		settings.ViaIR = *metadata.Settings.ViaIR
+	}
+	return settings
+}
+
+// distance counts the settings that differ from base, runs only count while the optimizer is on
+func (s SearchSettings) distance(base SearchSettings) int {
+	var n int
+	for _, changed := range []bool{
+		s.CompilerVersion != base.CompilerVersion,
+		s.Optimizer != base.Optimizer,
+		s.Optimizer && s.Runs != base.Runs,
+		s.EvmVersion != base.EvmVersion,
+		s.ViaIR != base.ViaIR,
+	} {
+		if changed {
+			n++
+		}
+	}
+	return n
+}
+
+// apply returns metadata as JSON with the candidate settings
+func (s SearchSettings) apply(metadata *SolcMetadata) (string, error) {
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	var candidate SolcMetadata
+	if err = json.Unmarshal(raw, &candidate); err != nil {
+		return "", err
+	}
+	candidate.Settings.Optimizer.Enabled = s.Optimizer
+	candidate.Settings.Optimizer.Runs = s.Runs
+	candidate.Settings.EvmVersion = s.EvmVersion
+	candidate.Settings.ViaIR = nil
+	if s.ViaIR {
+		viaIR := true
+		candidate.Settings.ViaIR = &viaIR
+	}
+	if candidate.Compiler != nil {
+		compiler := map[string]string{"version": shortVersion(s.CompilerVersion)}
+		candidate.Compiler = &compiler
+	}
+	raw, err = json.Marshal(candidate)
+	return string(raw), err
+}
+
+// searchGrid lists every candidate except base itself, closest first and at most limit of them
+func searchGrid(base SearchSettings, versions []string, limit int) []SearchSettings {
+	seen := map[SearchSettings]bool{base: true}
+	var grid []SearchSettings
+	for _, version := range append([]string{base.CompilerVersion}, versions...) {
+		for _, optimizer := range []bool{base.Optimizer, !base.Optimizer} {
+			runs := []int{base.Runs}
+			if optimizer {
+				runs = append(runs, searchRuns...)
+			}
+			for _, run := range runs {
+				for _, evm := range append([]struct{ name, since string }{{base.EvmVersion, "0.0.0"}}, searchEvmVersions...) {
+					if compareVersions(version, evm.since) < 0 {
+						continue
+					}
+					for _, viaIR := range []bool{base.ViaIR, !base.ViaIR} {
+						if viaIR && compareVersions(version, searchViaIRSince) < 0 {
+							continue
+						}
+						candidate := SearchSettings{CompilerVersion: version, Optimizer: optimizer, Runs: run, EvmVersion: evm.name, ViaIR: viaIR}
+						if !seen[candidate] {
+							seen[candidate] = true
+							grid = append(grid, candidate)
+						}
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(grid, func(i, j int) bool { return grid[i].distance(base) < grid[j].distance(base) })
+	if len(grid) > limit {
+		grid = grid[:limit]
+	}
+	return grid
+}
+
+// searchSettings recompiles metadata across the settings grid in parallel and returns the first candidate
+// that matches, nil if none did, with the number of candidates searched. Patch versions are only searched
+// when the on-chain code does not name its compiler
+func (v *VerificationRequest) searchSettings(ctx context.Context, metadata *SolcMetadata, chainBytecode string, onchainMetadata *BytecodeMetadata) (*searchResult, int, error) {
+	var versions []string
+	if onchainMetadata == nil || onchainMetadata.Solc == "" {
+		patches, err := SolcManagerInstance.PatchVersions(v.CompilerVersion)
+		if err != nil {
+			util.Logger().Error(fmt.Errorf("list solc patch versions of %s failed: %v", v.CompilerVersion, err))
+		}
+		versions = patches
+	}
+	grid := searchGrid(searchSettingsOf(v.CompilerVersion, metadata), versions, util.EnvInt("VERIFY_SEARCH_MAX_CANDIDATES", 64))
+	util.Logger().Info(fmt.Sprintf("search %d compiler settings for contract %s", len(grid), v.Address))
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	candidates := make(chan SearchSettings)
+	found := make(chan *searchResult, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < util.EnvInt("VERIFY_SEARCH_WORKERS", runtime.NumCPU()); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for candidate := range candidates {
+				result := v.trySettings(ctx, metadata, candidate, chainBytecode)
+				if result == nil {
+					continue
+				}
+				select {
+				case found <- result:
+					cancel()
+				default:
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, candidate := range grid {
+		select {
+		case candidates <- candidate:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(candidates)
+	wg.Wait()
+
+	select {
+	case result := <-found:
+		return result, len(grid), nil
+	default:
+	}
+	return nil, len(grid), parent.Err()
+}
+
+// searchMismatch searches the settings of a request that did not match, on success the request
+// takes the compiler version and metadata of the matching candidate
+func (v *VerificationRequest) searchMismatch(ctx context.Context, chainBytecode string, onchainMetadata *BytecodeMetadata) (*searchResult, int, error) {
+	// the compiled input was formatted for solc, start again from the submitted one
+	inputJson, err := v.VerifyMetadata()
+	if err != nil {
+		return nil, 0, err
+	}
+	metadata, ok := inputJson.(*SolcMetadata)
+	if !ok {
+		return nil, 0, nil
+	}
+	result, searched, err := v.searchSettings(ctx, metadata, chainBytecode, onchainMetadata)
+	if result != nil {
+		util.Logger().Info(fmt.Sprintf("search matched contract %s with %+v", v.Address, result.settings))
+		v.CompilerVersion = result.settings.CompilerVersion
+		v.Metadata = result.metadata
+		v.StandardJson = ""
+	}
+	return result, searched, err
+}
+
+func (v *VerificationRequest) trySettings(ctx context.Context, metadata *SolcMetadata, settings SearchSettings, chainBytecode string) *searchResult {
+	input, err := settings.apply(metadata)
+	if err != nil {
+		return nil
+	}
+	var candidate SolcMetadata
+	if err = json.Unmarshal([]byte(input), &candidate); err != nil {
+		return nil
+	}
+	if err = SolcManagerInstance.EnsureVersion(settings.CompilerVersion); err != nil {
+		return nil
+	}
+	output, err := candidate.recompileContract(ctx, settings.CompilerVersion)
+	if err != nil || ctx.Err() != nil {
+		return nil
+	}
+	match, err := v.compareBytecodes(ctx, chainBytecode, output)
+	if err != nil || match.Status == mismatch {
+		return nil
+	}
+	return &searchResult{settings: settings, metadata: input, output: output, match: match}
+}
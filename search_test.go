@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_searchGrid(t *testing.T) {
+	base := SearchSettings{CompilerVersion: "v0.8.19+commit.7dd6d404", Optimizer: true, Runs: 200}
+	grid := searchGrid(base, nil, 1000)
+	if len(grid) == 0 || grid[0].distance(base) != 1 {
+		t.Fatalf("expected the closest candidates first, got %+v", grid)
+	}
+	for i, candidate := range grid {
+		if candidate == base {
+			t.Errorf("the submitted settings are searched again")
+		}
+		if candidate.EvmVersion == "shanghai" || candidate.EvmVersion == "cancun" {
+			t.Errorf("evmVersion %s is not supported by 0.8.19", candidate.EvmVersion)
+		}
+		if i > 0 && candidate.distance(base) < grid[i-1].distance(base) {
+			t.Errorf("grid is not ordered by distance at %d", i)
+		}
+	}
+
+	old := SearchSettings{CompilerVersion: "v0.8.10+commit.fc410830"}
+	for _, candidate := range searchGrid(old, []string{"v0.8.9+commit.e5eed63a"}, 1000) {
+		if candidate.ViaIR {
+			t.Errorf("viaIR searched for %s", candidate.CompilerVersion)
+		}
+		if !candidate.Optimizer && candidate.Runs != 0 {
+			t.Errorf("runs varied with the optimizer off: %+v", candidate)
+		}
+	}
+
+	if grid = searchGrid(base, nil, 5); len(grid) != 5 {
+		t.Errorf("limit not applied, got %d candidates", len(grid))
+	}
+}
+
+func Test_compareVersions(t *testing.T) {
+	if compareVersions("0.8.9", "0.8.19") >= 0 || compareVersions("v0.8.19+commit.7dd6d404", "0.8.19") != 0 || compareVersions("0.10.0", "0.9.9") <= 0 {
+		t.Errorf("unexpected version order")
+	}
+}
+
+func Test_searchSettings(t *testing.T) {
+	// a fake solc that only reproduces the on-chain code when compiled with runs 1
+	version := "v0.8.19+commit.7dd6d404"
+	dir := t.TempDir()
+	script := `#!/bin/sh
+input=$(cat)
+case "$input" in
+*'"runs":1}'*) code=6080604052600080fd ;;
+*) code=60806040526000600080fd ;;
+esac
+echo '{"contracts":{"Token.sol":{"Token":{"abi":[],"evm":{"bytecode":{"object":"'$code'"},"deployedBytecode":{"object":"'$code'"}}}}}}'
+`
+	if err := os.WriteFile(filepath.Join(dir, version), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	previous := SolcManagerInstance
+	SolcManagerInstance = &SolcManager{cacheDir: dir}
+	defer func() { SolcManagerInstance = previous }()
+
+	metadata := &SolcMetadata{Language: "Solidity", Sources: SourcesCode{"Token.sol": {Content: "contract Token {}"}}}
+	metadata.Settings.Optimizer.Enabled = true
+	metadata.Settings.Optimizer.Runs = 200
+	metadata.Settings.CompilationTarget = map[string]string{"Token.sol": "Token"}
+
+	req := VerificationRequest{Address: "0x00000000001523057a05d6293c1e5171ee33ee0a", Chain: 46, CompilerVersion: version}
+	result, searched, err := req.searchSettings(context.Background(), metadata, "0x6080604052600080fd", &BytecodeMetadata{Solc: "0.8.19"})
+	if err != nil || result == nil {
+		t.Fatalf("search failed after %d candidates: %v", searched, err)
+	}
+	if result.settings.Runs != 1 || !result.settings.Optimizer || result.match.Status != perfect {
+		t.Errorf("unexpected result %+v %+v", result.settings, result.match)
+	}
+	if !strings.Contains(result.metadata, `"runs":1}`) || !strings.Contains(result.metadata, `"compilationTarget"`) {
+		t.Errorf("matched metadata %s", result.metadata)
+	}
+
+	// nothing matches code the fake solc never produces
+	result, searched, err = req.searchSettings(context.Background(), metadata, "0x6080604052fe", &BytecodeMetadata{Solc: "0.8.19"})
+	if err != nil || result != nil || searched == 0 {
+		t.Errorf("unexpected result %+v %d %v", result, searched, err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Compiler settings as found in the metadata of every solc release. Settings the structs below do not
+// know, such as those of a newer compiler, are kept in Extra and written back unchanged, a setting lost
+// on the way to solc changes the bytecode and the contract never matches.
+
+type SolcMetadataSetting struct {
+	StopAfter         string                 `json:"stopAfter,omitempty"`
+	Remappings        []string               `json:"remappings,omitempty"`
+	Optimizer         SolcOptimizer          `json:"optimizer"`
+	EvmVersion        string                 `json:"evmVersion,omitempty"`
+	ViaIR             *bool                  `json:"viaIR,omitempty"`
+	EOFVersion        *int                   `json:"eofVersion,omitempty"`
+	Debug             *SolcDebug             `json:"debug,omitempty"`
+	Metadata          *SolcMetadataHash      `json:"metadata,omitempty"`
+	Libraries         map[string]interface{} `json:"libraries,omitempty"`
+	ModelChecker      *SolcModelChecker      `json:"modelChecker,omitempty"`
+	CompilationTarget map[string]string      `json:"compilationTarget,omitempty"`
+	OutputSelection   interface{}            `json:"outputSelection,omitempty"`
+	Extra             extraFields            `json:"-"`
+}
+
+type SolcOptimizer struct {
+	Enabled bool                  `json:"enabled"`
+	Runs    int                   `json:"runs"`
+	Details *SolcOptimizerDetails `json:"details,omitempty"`
+	Extra   extraFields           `json:"-"`
+	// implicit is set when enabled was left out, metadata does so when details are customized
+	implicit bool
+}
+
+type SolcOptimizerDetails struct {
+	Peephole                               *bool           `json:"peephole,omitempty"`
+	Inliner                                *bool           `json:"inliner,omitempty"`
+	JumpdestRemover                        *bool           `json:"jumpdestRemover,omitempty"`
+	OrderLiterals                          *bool           `json:"orderLiterals,omitempty"`
+	Deduplicate                            *bool           `json:"deduplicate,omitempty"`
+	Cse                                    *bool           `json:"cse,omitempty"`
+	ConstantOptimizer                      *bool           `json:"constantOptimizer,omitempty"`
+	SimpleCounterForLoopUncheckedIncrement *bool           `json:"simpleCounterForLoopUncheckedIncrement,omitempty"`
+	Yul                                    *bool           `json:"yul,omitempty"`
+	YulDetails                             *SolcYulDetails `json:"yulDetails,omitempty"`
+	Extra                                  extraFields     `json:"-"`
+}
+
+type SolcYulDetails struct {
+	StackAllocation *bool       `json:"stackAllocation,omitempty"`
+	OptimizerSteps  *string     `json:"optimizerSteps,omitempty"`
+	Extra           extraFields `json:"-"`
+}
+
+type SolcDebug struct {
+	RevertStrings string      `json:"revertStrings,omitempty"`
+	DebugInfo     []string    `json:"debugInfo,omitempty"`
+	Extra         extraFields `json:"-"`
+}
+
+// SolcMetadataHash is settings.metadata, how the metadata is referenced from the bytecode
+type SolcMetadataHash struct {
+	AppendCBOR        *bool       `json:"appendCBOR,omitempty"`
+	UseLiteralContent *bool       `json:"useLiteralContent,omitempty"`
+	BytecodeHash      string      `json:"bytecodeHash,omitempty"`
+	Extra             extraFields `json:"-"`
+}
+
+type SolcModelChecker struct {
+	Contracts         map[string][]string `json:"contracts,omitempty"`
+	DivModNoSlacks    *bool               `json:"divModNoSlacks,omitempty"`
+	Engine            string              `json:"engine,omitempty"`
+	ExtCalls          string              `json:"extCalls,omitempty"`
+	Invariants        []string            `json:"invariants,omitempty"`
+	ShowProvedSafe    *bool               `json:"showProvedSafe,omitempty"`
+	ShowUnproved      *bool               `json:"showUnproved,omitempty"`
+	ShowUnsupported   *bool               `json:"showUnsupported,omitempty"`
+	Solvers           []string            `json:"solvers,omitempty"`
+	Targets           []string            `json:"targets,omitempty"`
+	Timeout           *int                `json:"timeout,omitempty"`
+	BmcLoopIterations *int                `json:"bmcLoopIterations,omitempty"`
+	Extra             extraFields         `json:"-"`
+}
+
+// extraFields are the members of a JSON object its Go type has no field for, and the empty objects and
+// arrays of the fields it has, which omitempty would otherwise drop
+type extraFields map[string]json.RawMessage
+
+// unmarshalKnown decodes data into v, a pointer to a struct, and returns the members left over
+func unmarshalKnown(data []byte, v any) (extraFields, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	known := jsonNames(reflect.TypeOf(v).Elem())
+	var extra extraFields
+	for name, value := range members {
+		if known[name] && !emptyJSON(value) {
+			continue
+		}
+		if extra == nil {
+			extra = extraFields{}
+		}
+		extra[name] = value
+	}
+	return extra, nil
+}
+
+// emptyJSON tells an empty object or array
+func emptyJSON(value json.RawMessage) bool {
+	var decoded any
+	if json.Unmarshal(value, &decoded) != nil {
+		return false
+	}
+	switch v := decoded.(type) {
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// marshalKnown encodes v with the extra members added back, a field set since keeps its value
+func marshalKnown(v any, extra extraFields) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := members[name]; !ok {
+			members[name] = value
+		}
+	}
+	return json.Marshal(members)
+}
+
+func jsonNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func (s *SolcMetadataSetting) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcMetadataSetting
+	s.Extra, err = unmarshalKnown(data, (*plain)(s))
+	return err
+}
+
+func (s SolcMetadataSetting) MarshalJSON() ([]byte, error) {
+	type plain SolcMetadataSetting
+	return marshalKnown(plain(s), s.Extra)
+}
+
+func (o *SolcOptimizer) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcOptimizer
+	if o.Extra, err = unmarshalKnown(data, (*plain)(o)); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	_ = json.Unmarshal(data, &members)
+	_, enabled := members["enabled"]
+	o.implicit = !enabled
+	return nil
+}
+
+func (o SolcOptimizer) MarshalJSON() ([]byte, error) {
+	if o.implicit && !o.Enabled {
+		// enabled is left out again rather than written as false next to the details
+		type implicit struct {
+			Runs    int                   `json:"runs"`
+			Details *SolcOptimizerDetails `json:"details,omitempty"`
+		}
+		return marshalKnown(implicit{Runs: o.Runs, Details: o.Details}, o.Extra)
+	}
+	type plain SolcOptimizer
+	return marshalKnown(plain(o), o.Extra)
+}
+
+func (d *SolcOptimizerDetails) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcOptimizerDetails
+	d.Extra, err = unmarshalKnown(data, (*plain)(d))
+	return err
+}
+
+func (d SolcOptimizerDetails) MarshalJSON() ([]byte, error) {
+	type plain SolcOptimizerDetails
+	return marshalKnown(plain(d), d.Extra)
+}
+
+func (d *SolcYulDetails) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcYulDetails
+	d.Extra, err = unmarshalKnown(data, (*plain)(d))
+	return err
+}
+
+func (d SolcYulDetails) MarshalJSON() ([]byte, error) {
+	type plain SolcYulDetails
+	return marshalKnown(plain(d), d.Extra)
+}
+
+func (d *SolcDebug) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcDebug
+	d.Extra, err = unmarshalKnown(data, (*plain)(d))
+	return err
+}
+
+func (d SolcDebug) MarshalJSON() ([]byte, error) {
+	type plain SolcDebug
+	return marshalKnown(plain(d), d.Extra)
+}
+
+func (m *SolcMetadataHash) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcMetadataHash
+	m.Extra, err = unmarshalKnown(data, (*plain)(m))
+	return err
+}
+
+func (m SolcMetadataHash) MarshalJSON() ([]byte, error) {
+	type plain SolcMetadataHash
+	return marshalKnown(plain(m), m.Extra)
+}
+
+func (m *SolcModelChecker) UnmarshalJSON(data []byte) (err error) {
+	type plain SolcModelChecker
+	m.Extra, err = unmarshalKnown(data, (*plain)(m))
+	return err
+}
+
+func (m SolcModelChecker) MarshalJSON() ([]byte, error) {
+	type plain SolcModelChecker
+	return marshalKnown(plain(m), m.Extra)
+}
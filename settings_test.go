@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// staticSettingsFiles are compiler inputs and metadata kept in static/, their settings are used as solc wrote them
+var staticSettingsFiles = []string{
+	"static/ORMP_metadata.json",
+	"static/example_metadata.json",
+	"static/example_input.json",
+	"static/request.json",
+}
+
+// staticSettings reads the settings of a metadata or compiler input file, or of the metadata of a request
+func staticSettings(t *testing.T, file string) json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content struct {
+		Settings json.RawMessage `json:"settings"`
+		Metadata string          `json:"metadata"`
+	}
+	if err = json.Unmarshal(data, &content); err != nil {
+		t.Fatal(err)
+	}
+	if content.Settings == nil && content.Metadata != "" {
+		if err = json.Unmarshal([]byte(content.Metadata), &content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if content.Settings == nil {
+		t.Fatalf("no settings in %s", file)
+	}
+	return content.Settings
+}
+
+// canonicalJSON orders object keys and keeps numbers as written, so that equal JSON compares byte for byte
+func canonicalJSON(t *testing.T, raw []byte) []byte {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("invalid json %s: %v", raw, err)
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return canonical
+}
+
+func assertSameJSON(t *testing.T, got, want []byte) {
+	t.Helper()
+	if got, want = canonicalJSON(t, got), canonicalJSON(t, want); !bytes.Equal(got, want) {
+		t.Errorf("round trip changed the settings\n got %s\nwant %s", got, want)
+	}
+}
+
+// settings modelled by this code that the static files do not use, and members it does not model
+const (
+	testModelledSettings = `{"compilationTarget":{"Token.sol":"Token"},"debug":{"debugInfo":["location","snippet"],"revertStrings":"debug"},"evmVersion":"cancun","libraries":{},"metadata":{"appendCBOR":false,"bytecodeHash":"none"},"optimizer":{"details":{"constantOptimizer":false,"cse":false,"deduplicate":false,"inliner":false,"jumpdestRemover":true,"orderLiterals":false,"peephole":true,"simpleCounterForLoopUncheckedIncrement":true,"yul":true,"yulDetails":{"optimizerSteps":"dhfoDgvulfnTUtnIf","stackAllocation":true}},"runs":200},"remappings":[],"viaIR":true}`
+	testUnknownSettings  = `{"evmVersion":"osaka","optimizer":{"details":{"fuse":true},"enabled":true,"level":3,"runs":200},"debug":{"revertStrings":"default","trace":true},"experimental":{"ssa":true}}`
+)
+
+func TestSolcMetadataSettingRoundTrip(t *testing.T) {
+	for _, file := range append(staticSettingsFiles, "") {
+		t.Run(file, func(t *testing.T) {
+			want := []byte(testModelledSettings)
+			if file != "" {
+				want = staticSettings(t, file)
+			}
+			var settings SolcMetadataSetting
+			if err := json.Unmarshal(want, &settings); err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+			raw, err := json.Marshal(settings)
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			assertSameJSON(t, raw, want)
+		})
+	}
+}
+
+// testEraSettings are the settings of metadata as each generation of solc writes them, keys sorted and
+// no whitespace. They are written by hand after the metadata format of those releases, not taken from a
+// compiler run, metadata produced by one belongs in staticSettingsFiles
+var testEraSettings = []struct {
+	name     string
+	settings string
+}{
+	// 0.4.x, bzzr0 swarm hash, no metadata member, libraries flat by file:name
+	{"0.4.24", `{"compilationTarget":{"WETH9.sol":"WETH9"},"evmVersion":"byzantium","libraries":{},"optimizer":{"enabled":false,"runs":200},"remappings":[]}`},
+	{"0.5.10 bzzr0", `{"compilationTarget":{"contracts/Token.sol":"Token"},"evmVersion":"petersburg","libraries":{"contracts/SafeMath.sol:SafeMath":"0x00000000001523057a05d6293c1e5171ee33ee0a"},"optimizer":{"enabled":true,"runs":200},"remappings":["openzeppelin-solidity/=node_modules/openzeppelin-solidity/"]}`},
+	{"0.5.16 bzzr1", `{"compilationTarget":{"contracts/UniswapV2Pair.sol":"UniswapV2Pair"},"evmVersion":"istanbul","libraries":{},"optimizer":{"enabled":true,"runs":999999},"remappings":[]}`},
+	{"0.6.12", `{"compilationTarget":{"contracts/Vault.sol":"Vault"},"evmVersion":"istanbul","libraries":{},"metadata":{"bytecodeHash":"ipfs","useLiteralContent":true},"optimizer":{"enabled":true,"runs":200},"remappings":[]}`},
+	// customized details replace enabled
+	{"0.7.6 details", `{"compilationTarget":{"contracts/Pool.sol":"Pool"},"evmVersion":"istanbul","libraries":{},"metadata":{"bytecodeHash":"ipfs"},"optimizer":{"details":{"constantOptimizer":true,"cse":true,"deduplicate":true,"inliner":false,"jumpdestRemover":true,"orderLiterals":true,"peephole":true,"yul":true,"yulDetails":{"optimizerSteps":"dhfoDgvulfnTUtnIf","stackAllocation":true}},"runs":800},"remappings":[]}`},
+	{"0.8.24 viaIR", `{"compilationTarget":{"src/Token.sol":"Token"},"evmVersion":"shanghai","libraries":{},"metadata":{"bytecodeHash":"ipfs"},"optimizer":{"details":{"constantOptimizer":true,"cse":true,"deduplicate":true,"inliner":true,"jumpdestRemover":true,"orderLiterals":true,"peephole":true,"simpleCounterForLoopUncheckedIncrement":true,"yul":true,"yulDetails":{"optimizerSteps":"dhfoDgvulfnTUtnIf[xa[r]EscLMcCTUtTOntnfDIulLculVcul [j]Tpeulxa[rul]xa[r]cLgvifCTUca[r]LSsTFOtfDnca[r]Iulc]jmul[jul] VcTOcul jmul:fDnTOcmu","stackAllocation":true}},"runs":200},"remappings":["@openzeppelin/=lib/openzeppelin-contracts/"],"viaIR":true}`},
+}
+
+// TestSolcMetadataSettingEras writes the settings back byte for byte, the order of the keys aside which
+// solc sorts and the decoded structs do not
+func TestSolcMetadataSettingEras(t *testing.T) {
+	for _, tt := range testEraSettings {
+		t.Run(tt.name, func(t *testing.T) {
+			var settings SolcMetadataSetting
+			if err := json.Unmarshal([]byte(tt.settings), &settings); err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+			raw, err := json.Marshal(settings)
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			if got := canonicalJSON(t, raw); string(got) != tt.settings {
+				t.Errorf("round trip changed the settings\n got %s\nwant %s", got, tt.settings)
+			}
+		})
+	}
+}
+
+func TestSolcMetadataSettingFields(t *testing.T) {
+	var settings SolcMetadataSetting
+	if err := json.Unmarshal([]byte(testModelledSettings), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.ViaIR == nil || !*settings.ViaIR || settings.Optimizer.Enabled || settings.Optimizer.Details == nil ||
+		settings.Optimizer.Details.YulDetails == nil || !strings.HasPrefix(*settings.Optimizer.Details.YulDetails.OptimizerSteps, "dhfoDgvulfnTUtnIf") ||
+		settings.Metadata.BytecodeHash != "none" {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	settings = SolcMetadataSetting{}
+	if err := json.Unmarshal([]byte(testUnknownSettings), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if string(settings.Extra["experimental"]) != `{"ssa":true}` || string(settings.Optimizer.Extra["level"]) != "3" ||
+		string(settings.Optimizer.Details.Extra["fuse"]) != "true" || string(settings.Debug.Extra["trace"]) != "true" {
+		t.Errorf("unknown settings not kept: %+v", settings)
+	}
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameJSON(t, raw, []byte(testUnknownSettings))
+}
+
+func TestSolcMetadataStringKeepsSettings(t *testing.T) {
+	for _, file := range staticSettingsFiles {
+		t.Run(file, func(t *testing.T) {
+			settings := staticSettings(t, file)
+			metadata := SolcMetadata{}
+			raw := `{"language":"Solidity","sources":{},"settings":` + string(settings) + `}`
+			if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+				t.Fatal(err)
+			}
+			var input struct {
+				Settings map[string]json.RawMessage `json:"settings"`
+			}
+			if err := json.Unmarshal([]byte(metadata.String()), &input); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := input.Settings["compilationTarget"]; ok {
+				t.Errorf("compilationTarget left in the compiler input")
+			}
+			// libraries are nested by file for solc and the output selection is ours
+			var want map[string]json.RawMessage
+			_ = json.Unmarshal(settings, &want)
+			for _, key := range []string{"compilationTarget", "libraries", "outputSelection"} {
+				delete(want, key)
+				delete(input.Settings, key)
+			}
+			got, _ := json.Marshal(input.Settings)
+			wanted, _ := json.Marshal(want)
+			assertSameJSON(t, got, wanted)
+		})
+	}
+}
+
+func TestSearchSettingsApplyKeepsSettings(t *testing.T) {
+	var metadata SolcMetadata
+	raw := `{"language":"Solidity","sources":{},"settings":` + testModelledSettings + `}`
+	if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := SearchSettings{CompilerVersion: "v0.8.26+commit.8a97fa7a", Optimizer: true, Runs: 1000, EvmVersion: "paris"}.apply(&metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var candidate SolcMetadata
+	if err = json.Unmarshal([]byte(applied), &candidate); err != nil {
+		t.Fatal(err)
+	}
+	settings := candidate.Settings
+	if !settings.Optimizer.Enabled || settings.Optimizer.Runs != 1000 || settings.EvmVersion != "paris" ||
+		settings.Debug == nil || settings.Debug.RevertStrings != "debug" || settings.Metadata == nil || *settings.Metadata.AppendCBOR ||
+		settings.Optimizer.Details == nil || !*settings.Optimizer.Details.SimpleCounterForLoopUncheckedIncrement {
+		t.Errorf("unexpected settings %s", applied)
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"verify-golang/util"
 )
@@ -14,8 +20,31 @@ import (
 type SolcManager struct {
 	versions sync.Map
 	cacheDir string
+	listURL  string
+	// repoURL is where the binaries listed in list.json are downloaded from
+	repoURL string
+
+	listMu sync.Mutex
+	list   *solcList
+}
+
+// solcList is the solc-bin list.json index of a platform
+type solcList struct {
+	Builds   []solcBuild       `json:"builds"`
+	Releases map[string]string `json:"releases"`
+}
+
+type solcBuild struct {
+	Path      string `json:"path"`
+	Keccak256 string `json:"keccak256"`
+	Sha256    string `json:"sha256"`
 }
 
+var (
+	ErrUnknownSolcVersion = errors.New("solc version not found in list.json")
+	ErrSolcChecksum       = errors.New("solc binary checksum mismatch")
+)
+
 var SolcManagerInstance *SolcManager
 
 const staticDirName = "static"
@@ -27,57 +56,236 @@ func NewSolcManager() *SolcManager {
 		panic(err)
 	}
 	staticDir := filepath.Join(dir, staticDirName)
-	return &SolcManager{cacheDir: staticDir}
+	repo := solcRepoFromEnv()
+	return &SolcManager{cacheDir: staticDir, listURL: repo + "list.json", repoURL: repo}
 }
 
+// EnsureVersion makes sure the binary of version is cached, concurrent calls for a version share one download
 func (sm *SolcManager) EnsureVersion(version string) error {
 	if _, ok := sm.versions.Load(version); ok {
 		return nil
 	}
 
 	versionDir := filepath.Join(sm.cacheDir, version)
-	if _, err := os.Stat(versionDir); err == nil {
+	return compilerDownloads.do("solc", version, versionDir, func(f *compilerFetch) error {
+		if _, err := os.Stat(versionDir); err == nil && sm.verifyCached(version, versionDir) {
+			sm.versions.Store(version, versionDir)
+			return nil
+		}
+
+		if offline() && isRemote(sm.repoURL) {
+			return fmt.Errorf("%w: solc %s is not cached in %s, prefetch it first", ErrOffline, version, sm.cacheDir)
+		}
+		util.Logger().Info(fmt.Sprintf("Start Downloading solc bin %s", version))
+		if err := sm.downloadSolc(version, f); err != nil {
+			return err
+		}
 		sm.versions.Store(version, versionDir)
 		return nil
-	}
-
-	util.Logger().Info(fmt.Sprintf("Start Downloading solc bin %s", version))
-	if err := sm.downloadSolc(version); err != nil {
-		return err
-	}
-	sm.versions.Store(version, versionDir)
-	return nil
+	})
 }
 
 const (
 	// github solc repo
-	GithubSolcRepoLinux = "https://github.com/argotorg/solc-bin/raw/gh-pages/linux-amd64/solc-linux-amd64-"
-	GithubSolcRepoMacos = "https://github.com/argotorg/solc-bin/raw/gh-pages/macosx-amd64/solc-macosx-amd64-"
+	GithubSolcRepoLinux = "https://github.com/argotorg/solc-bin/raw/gh-pages/linux-amd64/"
+	GithubSolcRepoMacos = "https://github.com/argotorg/solc-bin/raw/gh-pages/macosx-amd64/"
 )
 
-func (sm *SolcManager) downloadSolc(version string) error {
-	repo := GithubSolcRepoLinux
+func solcRepo() string {
 	if runtime.GOOS == "darwin" {
-		repo = GithubSolcRepoMacos
+		return GithubSolcRepoMacos
+	}
+	return GithubSolcRepoLinux
+}
+
+func solcPlatform() string {
+	if runtime.GOOS == "darwin" {
+		return "macosx-amd64"
+	}
+	return "linux-amd64"
+}
+
+// ResolveVersion maps a release such as "0.8.19" to its long version "v0.8.19+commit.7dd6d404"
+func (sm *SolcManager) ResolveVersion(version string) (string, error) {
+	list, err := sm.fetchList(false)
+	if err != nil {
+		return "", err
+	}
+	path, ok := list.Releases[version]
+	if !ok {
+		// the cached list may predate a new release
+		if list, err = sm.fetchList(true); err != nil {
+			return "", err
+		}
+		if path, ok = list.Releases[version]; !ok {
+			return "", fmt.Errorf("solc release %s not found", version)
+		}
+	}
+	return strings.TrimPrefix(path, fmt.Sprintf("solc-%s-", solcPlatform())), nil
+}
+
+// PatchVersions lists the long versions of every release with the major and minor of version, newest first
+func (sm *SolcManager) PatchVersions(version string) ([]string, error) {
+	list, err := sm.fetchList(false)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(shortVersion(version), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid solc version %s", version)
+	}
+	var releases []string
+	for release := range list.Releases {
+		if strings.HasPrefix(release, parts[0]+"."+parts[1]+".") {
+			releases = append(releases, release)
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return compareVersions(releases[i], releases[j]) > 0 })
+	versions := make([]string, 0, len(releases))
+	for _, release := range releases {
+		versions = append(versions, strings.TrimPrefix(list.Releases[release], fmt.Sprintf("solc-%s-", solcPlatform())))
+	}
+	return versions, nil
+}
+
+// compareVersions orders two "0.8.19" style versions
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(shortVersion(a), "."), strings.Split(shortVersion(b), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+func (sm *SolcManager) fetchList(refresh bool) (*solcList, error) {
+	sm.listMu.Lock()
+	defer sm.listMu.Unlock()
+	if sm.list != nil && !refresh {
+		return sm.list, nil
+	}
+
+	location := sm.listURL
+	if offline() && isRemote(location) {
+		// the copy prefetch left in the cache
+		location = filepath.Join(sm.cacheDir, cachedListName)
+		if _, err := os.Stat(location); err != nil {
+			return nil, fmt.Errorf("%w: no %s in %s, prefetch first", ErrOffline, cachedListName, sm.cacheDir)
+		}
+	}
+	body, _, err := openLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var list solcList
+	if err = json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, err
+	}
+	sm.list = &list
+	return sm.list, nil
+}
+
+// build looks version, "v0.8.19+commit.7dd6d404", up in list.json
+func (sm *SolcManager) build(version string) (*solcBuild, error) {
+	path := fmt.Sprintf("solc-%s-%s", solcPlatform(), version)
+	for _, refresh := range []bool{false, true} {
+		list, err := sm.fetchList(refresh)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Builds {
+			if list.Builds[i].Path == path {
+				return &list.Builds[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSolcVersion, version)
+}
+
+// verifyCached checks a cached binary against list.json once per process, a binary list.json does not know
+// or a list that cannot be fetched leaves it trusted. A corrupt binary is removed to be downloaded again
+func (sm *SolcManager) verifyCached(version, path string) bool {
+	build, err := sm.build(version)
+	if err != nil {
+		return true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if err = build.verify(f); err == nil {
+		return true
+	}
+	util.Logger().Warning(fmt.Sprintf("cached solc %s is corrupt, download it again: %v", version, err))
+	_ = os.Remove(path)
+	return false
+}
+
+// verify reads the whole binary and compares its sha256 and keccak256 with the ones of list.json
+func (b *solcBuild) verify(r io.Reader) error {
+	sha, keccak := sha256.New(), util.NewKeccak256()
+	if _, err := io.Copy(io.MultiWriter(sha, keccak), r); err != nil {
+		return err
+	}
+	for _, check := range []struct {
+		name, want string
+		got        []byte
+	}{{"sha256", b.Sha256, sha.Sum(nil)}, {"keccak256", b.Keccak256, keccak.Sum(nil)}} {
+		if check.want == "" {
+			continue
+		}
+		if got := hex.EncodeToString(check.got); !strings.EqualFold(util.TrimHex(check.want), got) {
+			return fmt.Errorf("%w: %s is %s, list.json says %s", ErrSolcChecksum, check.name, got, check.want)
+		}
+	}
+	if b.Sha256 == "" && b.Keccak256 == "" {
+		return fmt.Errorf("%w: list.json has no checksum for %s", ErrSolcChecksum, b.Path)
+	}
+	return nil
+}
+
+// downloadSolc downloads a build listed in list.json to a temp file, which only becomes the cached binary
+// once both checksums agree. progress receives every byte downloaded
+func (sm *SolcManager) downloadSolc(version string, progress *compilerFetch) error {
+	build, err := sm.build(version)
+	if err != nil {
+		return err
 	}
 	// https://raw.githubusercontent.com/ethereum/solc-bin/refs/heads/gh-pages/macosx-amd64/solc-macosx-amd64-v0.3.6%2Bcommit.988fe5e5
-	url := fmt.Sprintf("%s%s", repo, version)
+	url := sm.repoURL + build.Path
 
 	util.Logger().Info(fmt.Sprintf("Downloading solc from %s", url))
-	resp, err := http.Get(url)
+	body, size, err := openLocation(url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer body.Close()
+	progress.expect(size)
 
-	solcFile := filepath.Join(sm.cacheDir, version)
-	out, err := os.Create(solcFile)
+	tmp, err := os.CreateTemp(sm.cacheDir, ".tmp-solc-*")
 	if err != nil {
 		return err
 	}
-	_ = out.Chmod(0755)
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	defer os.Remove(tmp.Name())
+	if err = build.verify(io.TeeReader(body, io.MultiWriter(tmp, progress))); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(sm.cacheDir, version))
 }
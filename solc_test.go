@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"verify-golang/util"
+)
+
+const testSolcVersion = "v0.8.19+commit.7dd6d404"
+
+// solcRepoServer serves list.json with one build of testSolcVersion and binary as its file,
+// listed with the checksums of listed
+func solcRepoServer(t *testing.T, binary, listed string, status int) *SolcManager {
+	t.Helper()
+	path := "solc-" + solcPlatform() + "-" + testSolcVersion
+	sha := sha256.Sum256([]byte(listed))
+	list := solcList{
+		Builds:   []solcBuild{{Path: path, Sha256: "0x" + hex.EncodeToString(sha[:]), Keccak256: util.Keccak256Hex([]byte(listed))}},
+		Releases: map[string]string{"0.8.19": path},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/list.json":
+			_ = json.NewEncoder(w).Encode(list)
+		case "/" + path:
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(binary))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &SolcManager{cacheDir: t.TempDir(), listURL: server.URL + "/list.json", repoURL: server.URL + "/"}
+}
+
+func TestSolcManagerDownload(t *testing.T) {
+	sm := solcRepoServer(t, "#!/bin/sh\n", "#!/bin/sh\n", http.StatusOK)
+	if err := sm.EnsureVersion(testSolcVersion); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(sm.cacheDir, testSolcVersion))
+	if err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("solc not installed: %v", err)
+	}
+	if err = sm.EnsureVersion("v0.8.20+commit.a1b79de6"); !errors.Is(err, ErrUnknownSolcVersion) {
+		t.Errorf("expected ErrUnknownSolcVersion, got %v", err)
+	}
+}
+
+func TestSolcManagerRefusesInvalidBinaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		binary string
+		status int
+		err    string
+	}{
+		{"checksum", "#!/bin/sh\necho tampered\n", http.StatusOK, "checksum mismatch"},
+		{"not found page", "<html>404</html>", http.StatusNotFound, "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := solcRepoServer(t, tt.binary, "#!/bin/sh\n", tt.status)
+			if err := sm.EnsureVersion(testSolcVersion); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected %s, got %v", tt.err, err)
+			}
+			if entries, _ := os.ReadDir(sm.cacheDir); len(entries) != 0 {
+				t.Errorf("invalid binary cached: %v", entries)
+			}
+			if _, ok := sm.versions.Load(testSolcVersion); ok {
+				t.Errorf("invalid version remembered")
+			}
+		})
+	}
+}
+
+func TestSolcManagerReplacesCorruptCache(t *testing.T) {
+	sm := solcRepoServer(t, "#!/bin/sh\n", "#!/bin/sh\n", http.StatusOK)
+	target := filepath.Join(sm.cacheDir, testSolcVersion)
+	if err := os.WriteFile(target, []byte("<html>404</html>"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.EnsureVersion(testSolcVersion); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "#!/bin/sh\n" {
+		t.Errorf("corrupt binary kept: %q", content)
+	}
+}
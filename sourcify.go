@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"verify-golang/util"
+)
+
+// Sourcify compatible API, so tools such as `forge verify-contract --verifier sourcify`
+// and hardhat-verify can point at this service unmodified.
+// https://docs.sourcify.dev/docs/api/
+
+const sourcifyMaxUploadSize = 32 << 20
+
+var ErrSourcifyMetadataNotFound = errors.New("metadata file not found, did you include metadata.json?")
+
+type sourcifyVerifyRequest struct {
+	Address        string            `json:"address"`
+	Chain          json.RawMessage   `json:"chain"`
+	Files          map[string]string `json:"files"`
+	ChosenContract string            `json:"chosenContract,omitempty"`
+}
+
+type sourcifyResult struct {
+	Address    string            `json:"address"`
+	ChainId    string            `json:"chainId"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message,omitempty"`
+	LibraryMap map[string]string `json:"libraryMap"`
+}
+
+type sourcifyCheckResult struct {
+	Address  string   `json:"address"`
+	Status   string   `json:"status"`
+	ChainIds []string `json:"chainIds,omitempty"`
+}
+
+type sourcifyFile struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// isSourcifyRequest reports whether a /verify body uses the Sourcify shape, either
+// multipart files or a JSON body with a files map
+func isSourcifyRequest(r *http.Request, body []byte) bool {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return true
+	}
+	var probe struct {
+		Files map[string]json.RawMessage `json:"files"`
+	}
+	return json.Unmarshal(body, &probe) == nil && len(probe.Files) > 0
+}
+
+func parseSourcifyRequest(r *http.Request, body []byte) (*sourcifyVerifyRequest, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		var req sourcifyVerifyRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.ParseMultipartForm(sourcifyMaxUploadSize); err != nil {
+		return nil, err
+	}
+	req := sourcifyVerifyRequest{
+		Address:        r.FormValue("address"),
+		Chain:          json.RawMessage(strconv.Quote(r.FormValue("chain"))),
+		ChosenContract: r.FormValue("chosenContract"),
+		Files:          make(map[string]string),
+	}
+	for _, headers := range r.MultipartForm.File {
+		if err := readMultipartFiles(headers, req.Files); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
+
+func (s *sourcifyVerifyRequest) chainId() (int64, error) {
+	chain, err := strconv.ParseInt(strings.Trim(string(s.Chain), `"`), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chain %s", s.Chain)
+	}
+	return chain, nil
+}
+
+// toVerificationRequest picks the metadata file and fills every source content from the uploaded files
+func (s *sourcifyVerifyRequest) toVerificationRequest() (*VerificationRequest, error) {
+	chain, err := s.chainId()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	candidates := make(map[string]*SolcMetadata)
+	for name, content := range s.Files {
+		var metadata SolcMetadata
+		if json.Unmarshal([]byte(content), &metadata) != nil || metadata.Compiler == nil || len(metadata.Sources) == 0 {
+			continue
+		}
+		names = append(names, name)
+		candidates[name] = &metadata
+	}
+	if len(names) == 0 {
+		return nil, ErrSourcifyMetadataNotFound
+	}
+	sort.Strings(names)
+	chosen := 0
+	if s.ChosenContract != "" {
+		if chosen, err = strconv.Atoi(s.ChosenContract); err != nil || chosen < 0 || chosen >= len(names) {
+			return nil, fmt.Errorf("invalid chosenContract %s", s.ChosenContract)
+		}
+	} else if len(names) > 1 {
+		return nil, fmt.Errorf("multiple metadata files found, use chosenContract to pick one of %s", strings.Join(names, ", "))
+	}
+	metadata := candidates[names[chosen]]
+
+	var missing []string
+	for sourcePath, source := range metadata.Sources {
+		if source.Content != "" {
+			continue
+		}
+		content, ok := findSourceContent(s.Files, sourcePath, source)
+		if !ok {
+			missing = append(missing, sourcePath)
+			continue
+		}
+		source.Content = content
+		metadata.Sources[sourcePath] = source
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing source files: %s", strings.Join(missing, ", "))
+	}
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &VerificationRequest{
+		Address:         s.Address,
+		Chain:           chain,
+		Metadata:        string(raw),
+		CompilerVersion: (*metadata.Compiler)["version"],
+	}, nil
+}
+
+func sourcifyVerifyHandler(w http.ResponseWriter, r *http.Request, body []byte) {
+	sourcifyReq, err := parseSourcifyRequest(r, body)
+	if err != nil {
+		respondSourcifyError(w, http.StatusBadRequest, err)
+		return
+	}
+	req, err := sourcifyReq.toVerificationRequest()
+	if err != nil {
+		respondSourcifyError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = req.validate(); err != nil {
+		respondSourcifyError(w, http.StatusBadRequest, err)
+		return
+	}
+	resp, err := req.verify(r.Context(), nil)
+	if err != nil {
+		respondSourcifyError(w, http.StatusBadRequest, err)
+		return
+	}
+	result := sourcifyResult{
+		Address:    req.Address,
+		ChainId:    strconv.FormatInt(req.Chain, 10),
+		Status:     resp.VerifiedStatus,
+		LibraryMap: map[string]string{},
+	}
+	for name, address := range resp.Libraries {
+		result.LibraryMap[name] = address
+	}
+	respondSourcify(w, http.StatusOK, map[string][]sourcifyResult{"result": {result}})
+}
+
+// GET /check-by-addresses?addresses=0x..,0x..&chainIds=46,592
+func sourcifyCheckByAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	addresses := splitQueryList(r.URL.Query().Get("addresses"))
+	chainIds := splitQueryList(r.URL.Query().Get("chainIds"))
+	if len(addresses) == 0 || len(chainIds) == 0 {
+		respondSourcifyError(w, http.StatusBadRequest, errors.New("addresses and chainIds are required"))
+		return
+	}
+
+	results := make([]sourcifyCheckResult, 0, len(addresses))
+	for _, address := range addresses {
+		if !util.VerifyEthereumAddress(address) {
+			respondSourcifyError(w, http.StatusBadRequest, fmt.Errorf("invalid address %s", address))
+			return
+		}
+		result := sourcifyCheckResult{Address: address, Status: "false"}
+		for _, chainId := range chainIds {
+			chain, err := strconv.ParseInt(chainId, 10, 64)
+			if err != nil {
+				respondSourcifyError(w, http.StatusBadRequest, fmt.Errorf("invalid chainId %s", chainId))
+				return
+			}
+			contract, err := ContractStoreInstance.Get(chain, address)
+			if err != nil {
+				continue
+			}
+			if result.Status == "false" {
+				result.Status = contract.VerifiedStatus
+			}
+			result.ChainIds = append(result.ChainIds, chainId)
+		}
+		results = append(results, result)
+	}
+	respondSourcify(w, http.StatusOK, results)
+}
+
+// GET /files/{chain}/{address}
+func sourcifyFilesHandler(w http.ResponseWriter, r *http.Request) {
+	chain, err := strconv.ParseInt(r.PathValue("chain"), 10, 64)
+	if err != nil {
+		respondSourcifyError(w, http.StatusBadRequest, errors.New("invalid chain"))
+		return
+	}
+	address := r.PathValue("address")
+	if !util.VerifyEthereumAddress(address) {
+		respondSourcifyError(w, http.StatusBadRequest, InvalidValidAddress)
+		return
+	}
+	contract, err := ContractStoreInstance.Get(chain, address)
+	if err != nil {
+		respondSourcifyError(w, http.StatusNotFound, errors.New("files have not been found"))
+		return
+	}
+	respondSourcify(w, http.StatusOK, contract.sourcifyFiles())
+}
+
+func (c *VerifiedContract) sourcifyFiles() []sourcifyFile {
+	matchDir := "full_match"
+	if c.VerifiedStatus == partial {
+		matchDir = "partial_match"
+	}
+	base := fmt.Sprintf("/contracts/%s/%d/%s", matchDir, c.Chain, c.Address)
+
+	files := []sourcifyFile{{Name: "metadata.json", Path: base + "/metadata.json", Content: c.Metadata}}
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, sourcifyFile{Name: path.Base(name), Path: base + "/sources/" + name, Content: c.Sources[name].Content})
+	}
+	if c.ConstructorArgs != "" {
+		files = append(files, sourcifyFile{Name: "constructor-args.txt", Path: base + "/constructor-args.txt", Content: c.ConstructorArgs})
+	}
+	return files
+}
+
+func splitQueryList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func respondSourcify(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func respondSourcifyError(w http.ResponseWriter, status int, err error) {
+	respondSourcify(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func sourcifyTestFiles(t *testing.T) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile("static/example_metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata SolcMetadata
+	if err = json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	content := metadata.Sources["contracts/new.sol"].Content
+	// sourcify metadata only carries hashes and urls
+	metadata.Sources["contracts/new.sol"] = SolcSources{Keccak256: metadata.Sources["contracts/new.sol"].Keccak256}
+	raw, _ := json.Marshal(metadata)
+	return map[string]string{"metadata.json": string(raw), "new.sol": content}
+}
+
+func Test_sourcifyToVerificationRequest(t *testing.T) {
+	req := sourcifyVerifyRequest{
+		Address: "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f",
+		Chain:   json.RawMessage(`"46"`),
+		Files:   sourcifyTestFiles(t),
+	}
+	verification, err := req.toVerificationRequest()
+	if err != nil {
+		t.Fatalf("toVerificationRequest failed: %v", err)
+	}
+	if verification.Chain != 46 || verification.CompilerVersion != "0.8.9+commit.e5eed63a" {
+		t.Errorf("unexpected request %+v", verification)
+	}
+	input, err := verification.VerifyMetadata()
+	if err != nil {
+		t.Fatalf("VerifyMetadata failed: %v", err)
+	}
+	if !strings.Contains(input.(*SolcMetadata).Sources["contracts/new.sol"].Content, "contract YourContract") {
+		t.Errorf("source content not filled")
+	}
+
+	delete(req.Files, "new.sol")
+	if _, err = req.toVerificationRequest(); err == nil || !strings.Contains(err.Error(), "contracts/new.sol") {
+		t.Errorf("expected missing source error, got %v", err)
+	}
+}
+
+func Test_parseSourcifyMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	_ = writer.WriteField("address", "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f")
+	_ = writer.WriteField("chain", "46")
+	for name, content := range sourcifyTestFiles(t) {
+		part, _ := writer.CreateFormFile("files", name)
+		_, _ = part.Write([]byte(content))
+	}
+	_ = writer.Close()
+
+	r := httptest.NewRequest("POST", "/verify", &buf)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	body := buf.Bytes()
+	if !isSourcifyRequest(r, body) {
+		t.Fatalf("multipart request not detected")
+	}
+	req, err := parseSourcifyRequest(r, body)
+	if err != nil {
+		t.Fatalf("parseSourcifyRequest failed: %v", err)
+	}
+	if len(req.Files) != 2 {
+		t.Errorf("expected 2 files, got %d", len(req.Files))
+	}
+	if chain, _ := req.chainId(); chain != 46 {
+		t.Errorf("expected chain 46, got %d", chain)
+	}
+
+	plain := httptest.NewRequest("POST", "/verify", nil)
+	if isSourcifyRequest(plain, []byte(`{"metadata":"{}","chain":46}`)) {
+		t.Errorf("native request detected as sourcify")
+	}
+}
+
+func Test_parseSourcifyMultipartDuplicates(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	_ = writer.WriteField("address", "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f")
+	_ = writer.WriteField("chain", "46")
+	// uploads keep only the base name of a file
+	for _, name := range []string{"token/ERC20.sol", "mocks/ERC20.sol"} {
+		part, _ := writer.CreateFormFile("files", name)
+		_, _ = part.Write([]byte(name))
+	}
+	_ = writer.Close()
+
+	r := httptest.NewRequest("POST", "/verify", &buf)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	if _, err := parseSourcifyRequest(r, buf.Bytes()); !errors.Is(err, ErrDuplicateFileName) {
+		t.Errorf("expected ErrDuplicateFileName, got %v", err)
+	}
+}
+
+func Test_sourcifyLookupHandlers(t *testing.T) {
+	store := newTestStore(t)
+	ContractStoreInstance = store
+	defer func() { ContractStoreInstance = nil }()
+	_ = store.Save(&VerifiedContract{
+		Chain:          46,
+		Address:        "0x04e4d345b48e60dc3ee160ba682ff7b8654d461f",
+		VerifiedStatus: perfect,
+		Metadata:       `{"language":"Solidity"}`,
+		Sources:        SourcesCode{"contracts/new.sol": {Content: "contract YourContract {}"}},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /check-by-addresses", sourcifyCheckByAddressesHandler)
+	mux.HandleFunc("GET /files/{chain}/{address}", sourcifyFilesHandler)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/check-by-addresses?addresses=0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f,0x0000000000000000000000000000000000000001&chainIds=46,592", nil))
+	var checks []sourcifyCheckResult
+	if err := json.NewDecoder(rr.Body).Decode(&checks); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(checks) != 2 || checks[0].Status != perfect || len(checks[0].ChainIds) != 1 || checks[1].Status != "false" {
+		t.Errorf("unexpected check result %+v", checks)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/files/46/0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f", nil))
+	var files []sourcifyFile
+	if err := json.NewDecoder(rr.Body).Decode(&files); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "metadata.json" || files[1].Path != "/contracts/full_match/46/0x04e4d345b48e60dc3ee160ba682ff7b8654d461f/sources/contracts/new.sol" {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/files/592/0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"verify-golang/util"
+)
+
+var ErrContractNotFound = errors.New("contract not verified")
+
+// VerifiedContract is everything we know about a successful verification
+type VerifiedContract struct {
+	Chain                  int64             `json:"chain"`
+	Address                string            `json:"address"`
+	VerifiedStatus         string            `json:"verified_status"`
+	CompilerVersion        string            `json:"compiler_version"`
+	ReviveVersion          string            `json:"revive_version,omitempty"`
+	ContractName           string            `json:"contract_name"`
+	CompileTarget          string            `json:"compile_target"`
+	Abi                    []interface{}     `json:"abi"`
+	ConstructorArgs        string            `json:"constructor_args,omitempty"`
+	CreationBytecodeLength int               `json:"creation_bytecode_length"`
+	Metadata               string            `json:"metadata"`
+	Sources                SourcesCode       `json:"sources"`
+	Match                  *MatchDetails     `json:"match,omitempty"`
+	Immutables             map[string]string `json:"immutables,omitempty"`
+	Libraries              map[string]string `json:"libraries,omitempty"`
+	CreationMatch          bool              `json:"creation_match,omitempty"`
+	// Block is the block the code was read at, empty for the latest block
+	Block string `json:"block,omitempty"`
+	// Proxy is set on the record of a proxy, verified through the implementation it names
+	Proxy      *ProxyInfo `json:"proxy,omitempty"`
+	VerifiedAt time.Time  `json:"verified_at"`
+}
+
+func (c *VerifiedContract) response(message string) *VerificationResponse {
+	return &VerificationResponse{
+		VerifiedStatus:         c.VerifiedStatus,
+		Message:                message,
+		Abi:                    c.Abi,
+		CreationBytecodeLength: c.CreationBytecodeLength,
+		ReviveVersion:          c.ReviveVersion,
+		ContractName:           c.ContractName,
+		CompilerVersion:        c.CompilerVersion,
+		Match:                  c.Match,
+		Immutables:             c.Immutables,
+		ConstructorArgs:        c.ConstructorArgs,
+		DecodedConstructorArgs: decodedConstructorArgs(c.Abi, c.ConstructorArgs),
+		Libraries:              c.Libraries,
+		CreationMatch:          c.CreationMatch,
+		Proxy:                  c.Proxy,
+	}
+}
+
+// ContractStore persists verified contracts, Get returns ErrContractNotFound for unknown addresses
+type ContractStore interface {
+	Get(chain int64, address string) (*VerifiedContract, error)
+	Save(contract *VerifiedContract) error
+}
+
+var ContractStoreInstance ContractStore
+
+// FileStore keeps one JSON document per contract under <dir>/<chain>/<address>.json
+type FileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+const defaultStoreDir = "data"
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// NewFileStoreFromEnv uses VERIFY_STORE_DIR or ./data
+func NewFileStoreFromEnv() (*FileStore, error) {
+	dir := strings.TrimSpace(os.Getenv("VERIFY_STORE_DIR"))
+	if dir == "" {
+		dir = defaultStoreDir
+	}
+	return NewFileStore(dir)
+}
+
+func (fs *FileStore) path(chain int64, address string) string {
+	return filepath.Join(fs.dir, strconv.FormatInt(chain, 10), strings.ToLower(util.AddHex(address))+".json")
+}
+
+func (fs *FileStore) Get(chain int64, address string) (*VerifiedContract, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	data, err := os.ReadFile(fs.path(chain, address))
+	if os.IsNotExist(err) {
+		return nil, ErrContractNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var contract VerifiedContract
+	if err = json.Unmarshal(data, &contract); err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// Save writes to a temp file first so readers never observe a partial record
+func (fs *FileStore) Save(contract *VerifiedContract) error {
+	data, err := json.Marshal(contract)
+	if err != nil {
+		return err
+	}
+	target := fs.path(contract.Chain, contract.Address)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// SourceStore keeps source files addressed by keccak256, so metadata carrying only
+// hashes and urls can be resolved without the user pasting every file again
+type SourceStore struct {
+	dir string
+}
+
+var SourceStoreInstance *SourceStore
+
+var keccakHexRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func NewSourceStore(dir string) (*SourceStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &SourceStore{dir: dir}, nil
+}
+
+// NewSourceStoreFromEnv uses SOURCE_STORE_DIR or ./data/sources
+func NewSourceStoreFromEnv() (*SourceStore, error) {
+	dir := strings.TrimSpace(os.Getenv("SOURCE_STORE_DIR"))
+	if dir == "" {
+		dir = filepath.Join(defaultStoreDir, "sources")
+	}
+	return NewSourceStore(dir)
+}
+
+func (s *SourceStore) path(keccak256 string) (string, bool) {
+	key := strings.ToLower(util.TrimHex(keccak256))
+	if !keccakHexRegex.MatchString(key) {
+		return "", false
+	}
+	return filepath.Join(s.dir, key), true
+}
+
+// Load returns the content stored under keccak256, a nil store never resolves anything
+func (s *SourceStore) Load(keccak256 string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	target, ok := s.path(keccak256)
+	if !ok {
+		return "", false
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func (s *SourceStore) Save(content string) error {
+	if s == nil {
+		return nil
+	}
+	target, _ := s.path(util.Keccak256Hex([]byte(content)))
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// lookupVerified returns a perfect match of address recorded earlier, if any. The code at a past block may not
+// be the latest one, so requests at a block always verify and only matches at the latest block are returned.
+// Neither are those of a proxy, which may point to another implementation since
+func (v *VerificationRequest) lookupVerified(address string) *VerifiedContract {
+	if ContractStoreInstance == nil || v.historical() {
+		return nil
+	}
+	contract, err := ContractStoreInstance.Get(v.Chain, address)
+	if err != nil {
+		if !errors.Is(err, ErrContractNotFound) {
+			util.Logger().Error(fmt.Errorf("load verified contract %s on network %d failed: %v", address, v.Chain, err))
+		}
+		return nil
+	}
+	if contract.VerifiedStatus != perfect || contract.Block != "" || contract.Proxy != nil {
+		return nil
+	}
+	return contract
+}
+
+// historical tells a request reading the code at a given block rather than the latest one
+func (v *VerificationRequest) historical() bool {
+	block, err := v.blockParam()
+	return err == nil && block != "latest"
+}
+
+// inputSources returns the sources compiled, with those given by hash resolved from the source store
+func inputSources(input IMetadata) SourcesCode {
+	switch input := input.(type) {
+	case *SolcMetadata:
+		return input.Sources
+	case *ReviveMetadata:
+		return input.Sources
+	case *VyperMetadata:
+		return input.Sources
+	}
+	return nil
+}
+
+// saveVerified records the verified contract, and for a proxy verified through its implementation a
+// record of the proxy as well
+func (v *VerificationRequest) saveVerified(input IMetadata, compiledOutput *SolcOutput, verified *Match, proxy *ProxyInfo) {
+	if ContractStoreInstance == nil {
+		return
+	}
+	sources := inputSources(input)
+	var block string
+	if v.historical() {
+		block = strings.Trim(string(v.Block), `"`)
+	}
+	contract := compiledOutput.Contracts[compiledOutput.CompileTarget][compiledOutput.ContractName]
+	record := VerifiedContract{
+		Chain:                  v.Chain,
+		Address:                strings.ToLower(util.AddHex(v.codeAddress())),
+		VerifiedStatus:         verified.Status,
+		CompilerVersion:        v.CompilerVersion,
+		ReviveVersion:          compiledOutput.ReviveVersion,
+		ContractName:           compiledOutput.ContractName,
+		CompileTarget:          compiledOutput.CompileTarget,
+		Abi:                    contract.Abi,
+		ConstructorArgs:        verified.ConstructorArgs,
+		CreationBytecodeLength: len(contract.Evm.Bytecode.Object),
+		Metadata:               v.input(),
+		Sources:                sources,
+		Match:                  verified.Details,
+		Immutables:             verified.Immutables,
+		Libraries:              verified.Libraries,
+		CreationMatch:          verified.Creation,
+		Block:                  block,
+		VerifiedAt:             time.Now().UTC(),
+	}
+	saveRecord(&record)
+	if v.implementation != "" {
+		v.saveProxyRecord(record, proxy)
+	}
+	for sourcePath, source := range sources {
+		if err := SourceStoreInstance.Save(source.Content); err != nil {
+			util.Logger().Error(fmt.Errorf("save source %s failed: %v", sourcePath, err))
+		}
+	}
+}
+
+// saveProxyRecord records the proxy at Address with the verification of its implementation, so that the
+// proxy reads as verified and points to the implementation it was verified through
+func (v *VerificationRequest) saveProxyRecord(implementation VerifiedContract, proxy *ProxyInfo) {
+	implementation.Address = strings.ToLower(util.AddHex(v.Address))
+	implementation.Proxy = proxy
+	saveRecord(&implementation)
+}
+
+// saveRecord stores record, unless it is of a past block and the store, which keeps one record per address,
+// already has one of the latest code
+func saveRecord(record *VerifiedContract) {
+	if record.Block != "" {
+		if existing, err := ContractStoreInstance.Get(record.Chain, record.Address); err == nil && existing.Block == "" {
+			return
+		}
+	}
+	if err := ContractStoreInstance.Save(record); err != nil {
+		util.Logger().Error(fmt.Errorf("save verified contract %s on network %d failed: %v", record.Address, record.Chain, err))
+	}
+}
+
+// GET /contracts/{chain}/{address}
+func contractHandler(w http.ResponseWriter, r *http.Request) {
+	chain, err := strconv.ParseInt(r.PathValue("chain"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chain", http.StatusBadRequest)
+		return
+	}
+	address := r.PathValue("address")
+	if !util.VerifyEthereumAddress(address) {
+		http.Error(w, InvalidValidAddress.Error(), http.StatusBadRequest)
+		return
+	}
+	contract, err := ContractStoreInstance.Get(chain, address)
+	if errors.Is(err, ErrContractNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(contract)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verify-golang/util"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	return store
+}
+
+func Test_FileStore(t *testing.T) {
+	store := newTestStore(t)
+
+	if _, err := store.Get(46, "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f"); err != ErrContractNotFound {
+		t.Fatalf("expected ErrContractNotFound, got %v", err)
+	}
+
+	record := &VerifiedContract{
+		Chain:           46,
+		Address:         "0x04e4d345b48e60dc3ee160ba682ff7b8654d461f",
+		VerifiedStatus:  perfect,
+		CompilerVersion: "v0.8.17+commit.8df45f5f",
+		ContractName:    "ORMP",
+		Sources:         SourcesCode{"src/ORMP.sol": {Content: "contract ORMP {}"}},
+	}
+	if err := store.Save(record); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// address lookup is case-insensitive
+	got, err := store.Get(46, "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.ContractName != "ORMP" || got.Sources["src/ORMP.sol"].Content != "contract ORMP {}" {
+		t.Errorf("unexpected record %+v", got)
+	}
+}
+
+func Test_verifyShortCircuit(t *testing.T) {
+	store := newTestStore(t)
+	ContractStoreInstance = store
+	defer func() { ContractStoreInstance = nil }()
+
+	req := VerificationRequest{
+		Address:         "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f",
+		Chain:           -1, // unsupported network, any chain access would fail
+		CompilerVersion: "v0.8.17+commit.8df45f5f",
+	}
+	_ = store.Save(&VerifiedContract{Chain: req.Chain, Address: req.Address, VerifiedStatus: perfect, ContractName: "ORMP"})
+
+	resp, err := req.verify(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if resp.VerifiedStatus != perfect || resp.ContractName != "ORMP" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func Test_contractHandler(t *testing.T) {
+	store := newTestStore(t)
+	ContractStoreInstance = store
+	defer func() { ContractStoreInstance = nil }()
+	_ = store.Save(&VerifiedContract{Chain: 46, Address: "0x04e4d345b48e60dc3ee160ba682ff7b8654d461f", VerifiedStatus: partial})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /contracts/{chain}/{address}", contractHandler)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/contracts/46/0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var contract VerifiedContract
+	if err := json.NewDecoder(rr.Body).Decode(&contract); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+	if contract.VerifiedStatus != partial {
+		t.Errorf("unexpected status %s", contract.VerifiedStatus)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/contracts/46/0x0000000000000000000000000000000000000001", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %v", rr.Code)
+	}
+}
+
+func Test_saveVerified(t *testing.T) {
+	store := newTestStore(t)
+	ContractStoreInstance = store
+	defer func() { ContractStoreInstance = nil }()
+	sources, err := NewSourceStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SourceStoreInstance = sources
+	defer func() { SourceStoreInstance = nil }()
+	content := "contract A {}"
+	_ = sources.Save(content)
+
+	// the source is given by hash only and resolved from the source store
+	metadata := `{"language":"Solidity","sources":{"A.sol":{"keccak256":"` + util.Keccak256Hex([]byte(content)) + `"}},"settings":{"compilationTarget":{"A.sol":"A"}}}`
+	req := VerificationRequest{Address: "0x04e4D345b48E60Dc3EE160Ba682ff7B8654d461f", Chain: 46, CompilerVersion: "v0.8.17+commit.8df45f5f", Metadata: metadata}
+	input, err := req.VerifyMetadata()
+	if err != nil {
+		t.Fatalf("VerifyMetadata failed: %v", err)
+	}
+	output := &SolcOutput{CompileTarget: "A.sol", ContractName: "A", Contracts: map[string]map[string]SolcContract{"A.sol": {"A": {}}}}
+	req.saveVerified(input, output, &Match{Status: perfect}, nil)
+
+	contract := req.lookupVerified(req.Address)
+	if contract == nil || contract.Sources["A.sol"].Content != content {
+		t.Fatalf("resolved source not stored: %+v", contract)
+	}
+	if resp := contract.response("already verified"); resp.CompilerVersion != req.CompilerVersion || resp.ContractName != "A" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	// a verification at a past block neither uses nor stands for the latest one
+	historical := req
+	historical.Block = json.RawMessage(`100`)
+	if historical.lookupVerified(historical.Address) != nil {
+		t.Errorf("verification at a block short-circuited")
+	}
+	historical.saveVerified(input, output, &Match{Status: perfect}, nil)
+	if contract = req.lookupVerified(req.Address); contract == nil || contract.Block != "" {
+		t.Errorf("record of the latest code replaced: %+v", contract)
+	}
+	historical.Address, req.Address = "0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000001"
+	historical.saveVerified(input, output, &Match{Status: perfect}, nil)
+	if req.lookupVerified(req.Address) != nil {
+		t.Errorf("verification at a block used for the latest one")
+	}
+	if contract, _ = store.Get(req.Chain, req.Address); contract.Block != "100" {
+		t.Errorf("block not recorded: %+v", contract)
+	}
+}
+
+func Test_saveVerifiedProxy(t *testing.T) {
+	store := newTestStore(t)
+	ContractStoreInstance = store
+	defer func() { ContractStoreInstance = nil }()
+
+	input := &SolcMetadata{Sources: SourcesCode{"A.sol": {Content: "contract A {}"}}}
+	output := &SolcOutput{CompileTarget: "A.sol", ContractName: "A", Contracts: map[string]map[string]SolcContract{"A.sol": {"A": {}}}}
+	proxy := &ProxyInfo{Type: proxyMinimal, Address: testProxyAddress, Implementation: testImplementationAddress, Resolved: true}
+	req := VerificationRequest{Address: testProxyAddress, Chain: 46, implementation: testImplementationAddress}
+	req.saveVerified(input, output, &Match{Status: perfect}, proxy)
+
+	// the implementation is verified, the proxy is recorded as verified through it
+	if contract := req.lookupVerified(testImplementationAddress); contract == nil || contract.Proxy != nil || contract.ContractName != "A" {
+		t.Errorf("implementation not recorded: %+v", contract)
+	}
+	contract, err := store.Get(req.Chain, testProxyAddress)
+	if err != nil || contract.Proxy == nil || contract.Proxy.Implementation != testImplementationAddress || contract.Sources["A.sol"].Content == "" {
+		t.Fatalf("proxy not recorded: %+v, %v", contract, err)
+	}
+	if resp := contract.response("already verified"); resp.Proxy == nil || resp.ContractName != "A" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	// the implementation of a proxy may change, its record does not short-circuit
+	if req.lookupVerified(testProxyAddress) != nil {
+		t.Errorf("proxy record short-circuited")
+	}
+}
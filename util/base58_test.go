@@ -0,0 +1,21 @@
+package util
+
+import "testing"
+
+func TestBase58Encode(t *testing.T) {
+	tests := []struct {
+		input    []byte
+		expected string
+	}{
+		{[]byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{[]byte{0, 0, 1}, "112"},
+		{[]byte{}, ""},
+	}
+
+	for _, tt := range tests {
+		result := Base58Encode(tt.input)
+		if result != tt.expected {
+			t.Errorf("Base58Encode(%x) = %v; want %v", tt.input, result, tt.expected)
+		}
+	}
+}
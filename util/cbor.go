@@ -0,0 +1,138 @@
+package util
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Minimal CBOR (RFC 8949) decoder, enough for the metadata compilers append to bytecode.
+// Maps need text keys and indefinite lengths are rejected.
+
+var ErrCBORTruncated = errors.New("cbor: unexpected end of data")
+
+const cborMaxDepth = 16
+
+// DecodeCBOR decodes the first item of data and returns it together with the number of bytes consumed.
+// Values are uint64, int64, []byte, string, []any, map[string]any, bool, float64 or nil
+func DecodeCBOR(data []byte) (any, int, error) {
+	return decodeCBOR(data, 0)
+}
+
+func decodeCBOR(data []byte, depth int) (any, int, error) {
+	if depth > cborMaxDepth {
+		return nil, 0, errors.New("cbor: nesting too deep")
+	}
+	if len(data) == 0 {
+		return nil, 0, ErrCBORTruncated
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	if major == 7 {
+		return decodeCBORSimple(data, info)
+	}
+	arg, offset, err := cborArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		return arg, offset, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: negative integer overflow")
+		}
+		return -1 - int64(arg), offset, nil
+	case 2, 3:
+		if arg > uint64(len(data)-offset) {
+			return nil, 0, ErrCBORTruncated
+		}
+		end := offset + int(arg)
+		if major == 2 {
+			return append([]byte{}, data[offset:end]...), end, nil
+		}
+		return string(data[offset:end]), end, nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, 0, ErrCBORTruncated
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, n, err := decodeCBOR(data[offset:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			offset += n
+		}
+		return items, offset, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, 0, ErrCBORTruncated
+		}
+		items := make(map[string]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, n, err := decodeCBOR(data[offset:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset += n
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("cbor: unsupported map key %T", key)
+			}
+			value, n, err := decodeCBOR(data[offset:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			offset += n
+			items[name] = value
+		}
+		return items, offset, nil
+	}
+	return nil, 0, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+// cborArgument reads the length or value that follows the initial byte
+func cborArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < 1+size {
+			return 0, 0, ErrCBORTruncated
+		}
+		var value uint64
+		for _, b := range data[1 : 1+size] {
+			value = value<<8 | uint64(b)
+		}
+		return value, 1 + size, nil
+	}
+	return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+}
+
+func decodeCBORSimple(data []byte, info byte) (any, int, error) {
+	switch info {
+	case 20:
+		return false, 1, nil
+	case 21:
+		return true, 1, nil
+	case 22, 23:
+		return nil, 1, nil
+	case 26:
+		if len(data) < 5 {
+			return nil, 0, ErrCBORTruncated
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:5]))), 5, nil
+	case 27:
+		if len(data) < 9 {
+			return nil, 0, ErrCBORTruncated
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data[1:9])), 9, nil
+	}
+	return nil, 0, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
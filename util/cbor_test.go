@@ -0,0 +1,45 @@
+package util
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"0a", uint64(10)},
+		{"1903e8", uint64(1000)},
+		{"29", int64(-10)},
+		{"43000811", []byte{0, 8, 17}},
+		{"64736f6c63", "solc"},
+		{"f5", true},
+		{"83010203", []any{uint64(1), uint64(2), uint64(3)}},
+		{"a164736f6c6343000811", map[string]any{"solc": []byte{0, 8, 17}}},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.input)
+		result, n, err := DecodeCBOR(data)
+		if err != nil {
+			t.Errorf("DecodeCBOR(%s) failed: %v", tt.input, err)
+			continue
+		}
+		if n != len(data) {
+			t.Errorf("DecodeCBOR(%s) consumed %d of %d bytes", tt.input, n, len(data))
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("DecodeCBOR(%s) = %#v; want %#v", tt.input, result, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "430008", "a1", "5f", "a10102"} {
+		data, _ := hex.DecodeString(input)
+		if _, _, err := DecodeCBOR(data); err == nil {
+			t.Errorf("DecodeCBOR(%s) expected error", input)
+		}
+	}
+}
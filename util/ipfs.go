@@ -0,0 +1,80 @@
+package util
+
+import "crypto/sha256"
+
+// IPFS CIDv0 of a file as computed by solc (libsolutil/IpfsHash.cpp): unixfs protobuf leaves of
+// 256KiB, combined bottom up by nodes of at most 174 links, hashed with sha2-256
+
+const (
+	ipfsChunkSize   = 256 * 1024
+	ipfsMaxChildren = 174
+)
+
+type ipfsChunk struct {
+	hash      []byte
+	size      int
+	blockSize int
+}
+
+func protoVarint(n int) []byte {
+	var encoded []byte
+	for n > 0x7f {
+		encoded = append(encoded, byte(0x80|n&0x7f))
+		n >>= 7
+	}
+	return append(encoded, byte(n))
+}
+
+// protoBytes encodes a length delimited protobuf field
+func protoBytes(tag byte, data []byte) []byte {
+	return append(append([]byte{tag}, protoVarint(len(data))...), data...)
+}
+
+func ipfsMultihash(block []byte) []byte {
+	sum := sha256.Sum256(block)
+	return append([]byte{0x12, 0x20}, sum[:]...)
+}
+
+func ipfsCombine(links []ipfsChunk) ipfsChunk {
+	var data, sizes []byte
+	var chunk ipfsChunk
+	for _, link := range links {
+		chunk.size += link.size
+		chunk.blockSize += link.blockSize
+		// PBLink{Hash, Name: "", Tsize}
+		pbLink := protoBytes(0x0a, link.hash)
+		pbLink = append(pbLink, 0x12, 0x00, 0x18)
+		pbLink = append(pbLink, protoVarint(link.blockSize)...)
+		data = append(data, protoBytes(0x12, pbLink)...)
+		sizes = append(append(sizes, 0x20), protoVarint(link.size)...)
+	}
+	unixfs := append([]byte{0x08, 0x02, 0x18}, protoVarint(chunk.size)...)
+	block := append(data, protoBytes(0x0a, append(unixfs, sizes...))...)
+	chunk.blockSize += len(block)
+	chunk.hash = ipfsMultihash(block)
+	return chunk
+}
+
+// IpfsHash returns the sha2-256 multihash of data, base58 encoded it is the "Qm..." CIDv0
+func IpfsHash(data []byte) []byte {
+	var level []ipfsChunk
+	for offset := 0; offset == 0 || offset < len(data); offset += ipfsChunkSize {
+		part := data[offset:min(offset+ipfsChunkSize, len(data))]
+		unixfs := []byte{0x08, 0x02}
+		if len(part) > 0 {
+			unixfs = append(unixfs, protoBytes(0x12, part)...)
+		}
+		unixfs = append(append(unixfs, 0x18), protoVarint(len(part))...)
+		block := protoBytes(0x0a, unixfs)
+		level = append(level, ipfsChunk{hash: ipfsMultihash(block), size: len(part), blockSize: len(block)})
+	}
+
+	for len(level) > 1 {
+		var next []ipfsChunk
+		for start := 0; start < len(level); start += ipfsMaxChildren {
+			next = append(next, ipfsCombine(level[start:min(start+ipfsMaxChildren, len(level))]))
+		}
+		level = next
+	}
+	return level[0].hash
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIpfsHash(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{"hello\n", "QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN"},
+	}
+
+	for _, tt := range tests {
+		result := Base58Encode(IpfsHash([]byte(tt.input)))
+		if result != tt.expected {
+			t.Errorf("IpfsHash(%q) = %v; want %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestIpfsHashChunks(t *testing.T) {
+	// one byte over a chunk is a two link node, not a leaf of the first 256KiB
+	data := []byte(strings.Repeat("a", ipfsChunkSize+1))
+	root := IpfsHash(data)
+	if len(root) != 34 || root[0] != 0x12 || root[1] != 0x20 {
+		t.Fatalf("IpfsHash returned %x, want a sha2-256 multihash", root)
+	}
+	if string(root) == string(IpfsHash(data[:ipfsChunkSize])) {
+		t.Errorf("IpfsHash ignored the second chunk")
+	}
+}
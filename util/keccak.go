@@ -0,0 +1,139 @@
+package util
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math/bits"
+)
+
+// Keccak-256 as used by ethereum, the original keccak padding rather than the NIST SHA3 one
+
+const keccak256Rate = 136
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var keccakRotations = [24]int{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+
+var keccakPiLanes = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+
+func keccakF1600(state *[25]uint64) {
+	var bc [5]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for i := 0; i < 5; i++ {
+			bc[i] = state[i] ^ state[i+5] ^ state[i+10] ^ state[i+15] ^ state[i+20]
+		}
+		for i := 0; i < 5; i++ {
+			t := bc[(i+4)%5] ^ bits.RotateLeft64(bc[(i+1)%5], 1)
+			for j := 0; j < 25; j += 5 {
+				state[j+i] ^= t
+			}
+		}
+		// rho and pi
+		t := state[1]
+		for i := 0; i < 24; i++ {
+			lane := keccakPiLanes[i]
+			bc[0] = state[lane]
+			state[lane] = bits.RotateLeft64(t, keccakRotations[i])
+			t = bc[0]
+		}
+		// chi
+		for j := 0; j < 25; j += 5 {
+			for i := 0; i < 5; i++ {
+				bc[i] = state[j+i]
+			}
+			for i := 0; i < 5; i++ {
+				state[j+i] ^= ^bc[(i+1)%5] & bc[(i+2)%5]
+			}
+		}
+		// iota
+		state[0] ^= keccakRoundConstants[round]
+	}
+}
+
+type keccak256 struct {
+	state [25]uint64
+	buf   []byte
+}
+
+// NewKeccak256 returns a streaming Keccak-256 hash
+func NewKeccak256() hash.Hash {
+	return &keccak256{buf: make([]byte, 0, keccak256Rate)}
+}
+
+func (k *keccak256) absorb(block []byte) {
+	for i := 0; i < keccak256Rate/8; i++ {
+		k.state[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+	keccakF1600(&k.state)
+}
+
+func (k *keccak256) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(k.buf) > 0 {
+		fill := keccak256Rate - len(k.buf)
+		if fill > len(p) {
+			fill = len(p)
+		}
+		k.buf = append(k.buf, p[:fill]...)
+		p = p[fill:]
+		if len(k.buf) < keccak256Rate {
+			return n, nil
+		}
+		k.absorb(k.buf)
+		k.buf = k.buf[:0]
+	}
+	for len(p) >= keccak256Rate {
+		k.absorb(p[:keccak256Rate])
+		p = p[keccak256Rate:]
+	}
+	k.buf = append(k.buf, p...)
+	return n, nil
+}
+
+func (k *keccak256) Sum(b []byte) []byte {
+	// pad a copy so the hash can keep absorbing after Sum
+	clone := keccak256{state: k.state}
+	block := make([]byte, keccak256Rate)
+	copy(block, k.buf)
+	block[len(k.buf)] ^= 0x01
+	block[keccak256Rate-1] ^= 0x80
+	clone.absorb(block)
+
+	out := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(out[i*8:], clone.state[i])
+	}
+	return append(b, out...)
+}
+
+func (k *keccak256) Reset() {
+	k.state = [25]uint64{}
+	k.buf = k.buf[:0]
+}
+
+func (k *keccak256) Size() int { return 32 }
+
+func (k *keccak256) BlockSize() int { return keccak256Rate }
+
+// Keccak256 hashes the concatenation of data
+func Keccak256(data ...[]byte) []byte {
+	h := NewKeccak256()
+	for _, d := range data {
+		_, _ = h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// Keccak256Hex returns the 0x prefixed hex keccak256 of data
+func Keccak256Hex(data []byte) string {
+	return "0x" + hex.EncodeToString(Keccak256(data))
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{string(make([]byte, 8)), "011b4d03dd8c01f1049143cf9c4c817e4b167f1d1b83e5c6f0f10d89ba1e7bce"},
+		// longer than one block
+		{strings.Repeat("a", 200), "96ea54061def936c4be90b518992fdc6f12f535068a256229aca54267b4d084d"},
+	}
+
+	for _, tt := range tests {
+		result := hex.EncodeToString(Keccak256([]byte(tt.input)))
+		if result != tt.expected {
+			t.Errorf("Keccak256(%d bytes) = %v; want %v", len(tt.input), result, tt.expected)
+		}
+
+		// streaming writes across the block boundary
+		h := NewKeccak256()
+		for i := 0; i < len(tt.input); i += 7 {
+			end := i + 7
+			if end > len(tt.input) {
+				end = len(tt.input)
+			}
+			_, _ = h.Write([]byte(tt.input[i:end]))
+		}
+		if result = hex.EncodeToString(h.Sum(nil)); result != tt.expected {
+			t.Errorf("streaming Keccak256(%d bytes) = %v; want %v", len(tt.input), result, tt.expected)
+		}
+	}
+}
+
+func TestKeccak256Hex(t *testing.T) {
+	if result := Keccak256Hex([]byte("abc")); result != "0x4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45" {
+		t.Errorf("Keccak256Hex(abc) = %v", result)
+	}
+}
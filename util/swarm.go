@@ -0,0 +1,72 @@
+package util
+
+import "encoding/binary"
+
+// Swarm hashes solc embedded before switching to IPFS (libsolutil/SwarmHash.cpp),
+// bzzr0 up to 0.5.11 and the binary merkle tree bzzr1 from 0.5.12
+
+const swarmChunkSize = 0x1000
+
+// swarmBranches is the number of 32 byte child hashes an intermediate chunk holds
+const swarmBranches = swarmChunkSize / 32
+
+func swarmLength(size int) []byte {
+	length := make([]byte, 8)
+	binary.LittleEndian.PutUint64(length, uint64(size))
+	return length
+}
+
+// swarmSpan is the largest size a single child of a node covering size bytes represents
+func swarmSpan(size int) int {
+	span := swarmChunkSize
+	for span*swarmBranches < size {
+		span *= swarmBranches
+	}
+	return span
+}
+
+// Bzzr0Hash is the legacy swarm hash of data
+func Bzzr0Hash(data []byte) []byte {
+	if len(data) <= swarmChunkSize {
+		return Keccak256(swarmLength(len(data)), data)
+	}
+	span := swarmSpan(len(data))
+	var children []byte
+	for offset := 0; offset < len(data); offset += span {
+		children = append(children, Bzzr0Hash(data[offset:min(offset+span, len(data))])...)
+	}
+	return Keccak256(swarmLength(len(data)), children)
+}
+
+func bmtHash(data []byte) []byte {
+	if len(data) <= 64 {
+		return Keccak256(data)
+	}
+	mid := len(data) / 2
+	return Keccak256(bmtHash(data[:mid]), bmtHash(data[mid:]))
+}
+
+func bzzr1Chunk(data []byte, forceHigherLevel bool) []byte {
+	var content []byte
+	if len(data) < swarmChunkSize || len(data) == swarmChunkSize && !forceHigherLevel {
+		content = append(content, data...)
+	} else {
+		span := swarmSpan(len(data))
+		// a full 4KiB remainder under a taller node still needs its own level of chunk hashes
+		forceHigher := span > swarmChunkSize
+		for offset := 0; offset < len(data); offset += span {
+			content = append(content, bzzr1Chunk(data[offset:min(offset+span, len(data))], forceHigher)...)
+		}
+	}
+	padded := make([]byte, swarmChunkSize)
+	copy(padded, content)
+	return Keccak256(swarmLength(len(data)), bmtHash(padded))
+}
+
+// Bzzr1Hash is the binary merkle tree swarm hash of data, all zero for empty data
+func Bzzr1Hash(data []byte) []byte {
+	if len(data) == 0 {
+		return make([]byte, 32)
+	}
+	return bzzr1Chunk(data, false)
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestBzzr0Hash(t *testing.T) {
+	tests := []struct {
+		size     int
+		expected string
+	}{
+		{0, "011b4d03dd8c01f1049143cf9c4c817e4b167f1d1b83e5c6f0f10d89ba1e7bce"},
+		{swarmChunkSize - 1, "65a29a1bbfe9cf63a4c7b7779ece4c17bc46500918188f7a9f8e6134a95a433e"},
+	}
+
+	for _, tt := range tests {
+		result := hex.EncodeToString(Bzzr0Hash([]byte(strings.Repeat("A", tt.size))))
+		if result != tt.expected {
+			t.Errorf("Bzzr0Hash(%d bytes) = %v; want %v", tt.size, result, tt.expected)
+		}
+	}
+}
+
+func TestBzzr1Hash(t *testing.T) {
+	if result := hex.EncodeToString(Bzzr1Hash(nil)); result != strings.Repeat("0", 64) {
+		t.Errorf("Bzzr1Hash(empty) = %v; want zero hash", result)
+	}
+	// a full chunk is a leaf, one more byte adds a level of chunk hashes
+	full := []byte(strings.Repeat("A", swarmChunkSize+1))
+	if hex.EncodeToString(Bzzr1Hash(full[:swarmChunkSize])) == hex.EncodeToString(Bzzr1Hash(full)) {
+		t.Errorf("Bzzr1Hash ignored the byte past the first chunk")
+	}
+}
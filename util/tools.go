@@ -1,7 +1,10 @@
 package util
 
 import (
+	"encoding/hex"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -10,6 +13,19 @@ func VerifyEthereumAddress(accountId string) bool {
 	return ethAddressRegex.MatchString(AddHex(accountId))
 }
 
+// ChecksumAddress returns the EIP-55 mixed case form of a 20 byte hex address
+func ChecksumAddress(address string) string {
+	lower := strings.ToLower(TrimHex(address))
+	hash := hex.EncodeToString(Keccak256([]byte(lower)))
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
 func AddHex(s string) string {
 	if strings.TrimSpace(s) == "" || strings.TrimSpace(s) == "null" {
 		return ""
@@ -23,3 +39,12 @@ func AddHex(s string) string {
 func TrimHex(s string) string {
 	return strings.TrimPrefix(s, "0x")
 }
+
+// EnvInt reads a positive integer from the environment, falling back to def when unset or invalid
+func EnvInt(key string, def int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
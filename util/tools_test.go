@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -43,6 +44,21 @@ func TestAddHex(t *testing.T) {
 	}
 }
 
+func TestChecksumAddress(t *testing.T) {
+	tests := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+
+	for _, expected := range tests {
+		if result := ChecksumAddress(strings.ToLower(expected)); result != expected {
+			t.Errorf("ChecksumAddress(%s) = %v; want %v", strings.ToLower(expected), result, expected)
+		}
+	}
+}
+
 func TestTrimHex(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -60,3 +76,24 @@ func TestTrimHex(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"8", 8},
+		{" 3 ", 3},
+		{"", 5},
+		{"abc", 5},
+		{"-1", 5},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("UTIL_TEST_ENV_INT", tt.value)
+		result := EnvInt("UTIL_TEST_ENV_INT", 5)
+		if result != tt.expected {
+			t.Errorf("EnvInt(%q) = %v; want %v", tt.value, result, tt.expected)
+		}
+	}
+}
@@ -2,51 +2,99 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"verify-golang/evm"
 	"verify-golang/util"
 )
 
-type EthRpcRes struct {
-	Result string
-}
+var ErrInvalidBlock = errors.New("invalid block, expected a block number or a 32 byte block hash")
 
-func (v *VerificationRequest) fetchChainBytecode(ctx context.Context) (string, error) {
-	chain, ok := chainGroup[v.Chain]
-	if !ok {
-		return "", fmt.Errorf("network %d not supported", v.Chain)
+// blockParam turns Block into the block parameter of eth_getCode: latest when unset, a hex quantity
+// for a block number and an EIP-1898 object for a block hash
+func (v *VerificationRequest) blockParam() (any, error) {
+	block := strings.TrimSpace(strings.Trim(string(v.Block), `"`))
+	switch block {
+	case "", "null":
+		return "latest", nil
+	case "latest", "earliest", "safe", "finalized":
+		return block, nil
+	}
+	if hexBlock, ok := strings.CutPrefix(block, "0x"); ok {
+		if len(hexBlock) == 64 {
+			if _, err := hex.DecodeString(hexBlock); err != nil {
+				return nil, ErrInvalidBlock
+			}
+			return map[string]any{"blockHash": block, "requireCanonical": true}, nil
+		}
+		number, err := strconv.ParseUint(hexBlock, 16, 64)
+		if err != nil {
+			return nil, ErrInvalidBlock
+		}
+		return "0x" + strconv.FormatUint(number, 16), nil
+	}
+	number, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidBlock
 	}
-	randomId := rand.Intn(100000)
-	data, err := util.PostWithJson(ctx, []byte(fmt.Sprintf(`{"id": %d,"jsonrpc": "2.0","params": ["%s","latest"],"method": "eth_getCode"}`, randomId, v.Address)), chain.Rpc[0])
+	return "0x" + strconv.FormatUint(number, 16), nil
+}
 
+func (v *VerificationRequest) fetchChainBytecode(ctx context.Context) (string, error) {
+	client, err := rpcClientOf(v.Chain)
 	if err != nil {
 		return "", err
 	}
-	var result EthRpcRes
-	err = json.Unmarshal(data, &result)
+	block, err := v.blockParam()
 	if err != nil {
 		return "", err
 	}
-	return result.Result, nil
+	return client.GetCode(ctx, v.codeAddress(), block)
+}
+
+// codeAddress is the address whose code is verified, the implementation of a proxy followed by resolveProxy
+func (v *VerificationRequest) codeAddress() string {
+	if v.implementation != "" {
+		return v.implementation
+	}
+	return v.Address
+}
+
+// fetchCreationCode falls back to the creation code of an address without code,
+// ErrBytecodeNotFound when there is none either
+func (v *VerificationRequest) fetchCreationCode(ctx context.Context) error {
+	creationCode, err := v.fetchCreationInput(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBytecodeNotFound, err)
+	}
+	if util.TrimHex(creationCode) == "" {
+		return ErrBytecodeNotFound
+	}
+	util.Logger().Info(fmt.Sprintf("contract %s has no code, compare its creation code", v.Address))
+	v.creationCode = util.TrimHex(creationCode)
+	return nil
 }
 
 func (v *VerificationRequest) VerifyMetadata() (IMetadata, error) {
+	if v.StandardJson != "" {
+		return v.verifyStandardJson()
+	}
 	var metadata SolcMetadata
 	err := json.Unmarshal([]byte(v.Metadata), &metadata)
 	if err != nil {
 		return nil, InvalidValidInputMetadata
 	}
-	if len(metadata.Sources) == 0 {
-		return nil, InvalidValidInputMetadata
+	if metadata.Language == languageVyper {
+		return v.verifyVyper(v.Metadata)
 	}
-	for _, source := range metadata.Sources {
-		if source.Content == "" {
-			return nil, InvalidValidInputMetadata
-		}
+	if err = metadata.Sources.validate(); err != nil {
+		return nil, err
 	}
 	// detect if is revive metadata
 	if metadata.ResolcVersion != "" {
@@ -55,6 +103,108 @@ func (v *VerificationRequest) VerifyMetadata() (IMetadata, error) {
 	return &metadata, nil
 }
 
+// verifyStandardJson accepts a solc standard JSON input as produced by Foundry or Hardhat build-info,
+// the compilation target comes from ContractName instead of settings.compilationTarget
+func (v *VerificationRequest) verifyStandardJson() (IMetadata, error) {
+	var input SolcMetadata
+	if err := json.Unmarshal([]byte(v.StandardJson), &input); err != nil {
+		return nil, InvalidValidInputMetadata
+	}
+	if input.Language == languageVyper {
+		return v.verifyVyper(v.StandardJson)
+	}
+	if input.Language != "" && input.Language != "Solidity" {
+		return nil, fmt.Errorf("unsupported language %s", input.Language)
+	}
+	if err := input.Sources.validate(); err != nil {
+		return nil, err
+	}
+	sourcePath, contractName := splitContractName(v.ContractName)
+	if sourcePath == "" || contractName == "" {
+		return nil, InvalidContractName
+	}
+	if _, ok := input.Sources[sourcePath]; !ok {
+		return nil, fmt.Errorf("source %s not found in standard json input", sourcePath)
+	}
+	input.Language = "Solidity"
+	input.Settings.CompilationTarget = map[string]string{sourcePath: contractName}
+	return &input, nil
+}
+
+type SourceHashMismatch struct {
+	Path     string `json:"path"`
+	Declared string `json:"declared"`
+	Computed string `json:"computed"`
+}
+
+// SourceIntegrityError lists every source that is missing or does not match its declared keccak256
+type SourceIntegrityError struct {
+	Mismatched []SourceHashMismatch
+	Missing    []string
+}
+
+func (e *SourceIntegrityError) Error() string {
+	var parts []string
+	if len(e.Mismatched) > 0 {
+		files := make([]string, 0, len(e.Mismatched))
+		for _, m := range e.Mismatched {
+			files = append(files, fmt.Sprintf("%s (declared %s, computed %s)", m.Path, m.Declared, m.Computed))
+		}
+		parts = append(parts, "source keccak256 mismatch: "+strings.Join(files, ", "))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing source content: "+strings.Join(e.Missing, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// validate resolves sources given only by hash from the local source store
+// and checks every content against its declared keccak256 before we spend a compile on it
+func (s SourcesCode) validate() error {
+	if len(s) == 0 {
+		return InvalidValidInputMetadata
+	}
+	paths := make([]string, 0, len(s))
+	for sourcePath := range s {
+		paths = append(paths, sourcePath)
+	}
+	sort.Strings(paths)
+
+	var integrity SourceIntegrityError
+	for _, sourcePath := range paths {
+		source := s[sourcePath]
+		if source.Content == "" && source.Keccak256 != "" {
+			if content, ok := SourceStoreInstance.Load(source.Keccak256); ok {
+				source.Content = content
+				s[sourcePath] = source
+			}
+		}
+		if source.Content == "" {
+			integrity.Missing = append(integrity.Missing, sourcePath)
+			continue
+		}
+		if source.Keccak256 == "" {
+			continue
+		}
+		computed := util.Keccak256Hex([]byte(source.Content))
+		if !strings.EqualFold(util.AddHex(source.Keccak256), computed) {
+			integrity.Mismatched = append(integrity.Mismatched, SourceHashMismatch{Path: sourcePath, Declared: source.Keccak256, Computed: computed})
+		}
+	}
+	if len(integrity.Mismatched) > 0 || len(integrity.Missing) > 0 {
+		return &integrity
+	}
+	return nil
+}
+
+// splitContractName splits "path/File.sol:Name" into the source path and contract name
+func splitContractName(name string) (string, string) {
+	if index := strings.LastIndex(name, ":"); index >= 0 {
+		return name[:index], name[index+1:]
+	}
+	return "", name
+}
+
 type SubscanRes struct {
 	Code int `json:"code"`
 	Data struct {
@@ -95,100 +245,199 @@ func fetchCreateBytecode(ctx context.Context, address string, networkID int64) (
 type Match struct {
 	Status          string
 	ConstructorArgs string
+	Details         *MatchDetails
+	// Immutables holds the on-chain value of each immutable by AST id
+	Immutables map[string]string
+	// Libraries maps each linked library, "file:Lib", to its address
+	Libraries map[string]string
+	// Diagnostics explains a mismatch, one entry per compiled contract
+	Diagnostics []MismatchDiagnostic
+	// Creation tells that the creation code was compared, the address having no code
+	Creation bool
 }
 
 func (v *VerificationRequest) compareBytecodes(ctx context.Context, chainBytecode string, compiledOutput *SolcOutput) (*Match, error) {
+	if v.creationCode != "" {
+		return v.compareCreationBytecodes(compiledOutput), nil
+	}
+	if compiledOutput.Language == languageVyper {
+		return v.compareVyperBytecodes(chainBytecode, compiledOutput), nil
+	}
 	trimmedChainBytecode := util.TrimHex(BytecodeWithoutMetadata(chainBytecode))
 	trimmedRawChainBytecode := util.TrimHex(chainBytecode)
 	createData := ""
 	fetchedCreateData := false
+	var createErr error
+	var diagnostics []MismatchDiagnostic
 
-	for compileTarget, contracts := range compiledOutput.Contracts {
+	for compileTarget, contracts := range compiledOutput.candidates() {
 		for contractName, contract := range contracts {
-			recompileDeployCodeWithLibraries := addLibraryAddresses(contract.Evm.DeployedBytecode.Object, chainBytecode).Replaced
-			if util.TrimHex(recompileDeployCodeWithLibraries) == trimmedRawChainBytecode {
-				compiledOutput.CompileTarget = compileTarget
-				compiledOutput.ContractName = contractName
-				return &Match{Status: perfect}, nil
+			// interfaces and abstract contracts have no code to compare
+			if contract.Evm.DeployedBytecode.Object == "" {
+				continue
 			}
-
+			// EOF containers keep their metadata inside the data section and are compared section by section
+			if compiledCode := decodeCode(util.TrimHex(contract.Evm.DeployedBytecode.Object)); evm.IsEOF(compiledCode) {
+				chainCode := decodeCode(trimmedRawChainBytecode)
+				diff, err := evm.CompareEOF(compiledCode, chainCode)
+				if err == nil && (diff == nil || diff.MetadataOnly()) {
+					compiledOutput.CompileTarget = compileTarget
+					compiledOutput.ContractName = contractName
+					if diff == nil {
+						return &Match{Status: perfect}, nil
+					}
+					return &Match{Status: partial}, nil
+				}
+				diagnostics = append(diagnostics, diagnoseEOFMismatch(compileTarget+":"+contractName, compiledCode, chainCode, diff, err))
+				continue
+			}
+			argsDiffer := false
+			// mismatches are diagnosed on the linked code, before the on-chain immutables hide their differences
+			linkedDeployCode, libraries := linkLibraries(util.TrimHex(contract.Evm.DeployedBytecode.Object), trimmedRawChainBytecode, contract.Evm.DeployedBytecode.LinkReferences, compiledOutput.Libraries)
+			recompileDeployCodeWithLibraries, immutables := replaceImmutables(linkedDeployCode, trimmedRawChainBytecode, contract.Evm.DeployedBytecode.ImmutableReferences)
 			trimmedWithLibraries := util.TrimHex(BytecodeWithoutMetadata(recompileDeployCodeWithLibraries))
-			if trimmedChainBytecode == trimmedWithLibraries {
-				compiledOutput.CompileTarget = compileTarget
-				compiledOutput.ContractName = contractName
-				return &Match{Status: partial}, nil
+			status := mismatch
+			switch {
+			case util.TrimHex(recompileDeployCodeWithLibraries) == trimmedRawChainBytecode:
+				status = perfect
+			case trimmedChainBytecode == trimmedWithLibraries:
+				status = partial
+			}
+			if status != mismatch {
+				var constructorArgs string
+				constructorArgs, argsDiffer = v.checkConstructorArgs(ctx, contract, compiledOutput.Libraries)
+				if !argsDiffer {
+					compiledOutput.CompileTarget = compileTarget
+					compiledOutput.ContractName = contractName
+					return &Match{Status: status, ConstructorArgs: constructorArgs, Immutables: immutables, Libraries: libraries}, nil
+				}
+				diagnostics = append(diagnostics, diagnoseMismatch(compileTarget+":"+contractName, util.TrimHex(linkedDeployCode), trimmedRawChainBytecode, contract, argsDiffer))
+				continue
 			}
 
 			if len(trimmedChainBytecode) == len(trimmedWithLibraries) {
 				if !fetchedCreateData {
-					var err error
-					createData, err = fetchCreateBytecode(ctx, v.Address, v.Chain)
-					if err != nil {
-						return &Match{Status: mismatch}, fmt.Errorf("fetch create bytecode failed: please retry later")
+					createData, createErr = v.cachedCreationInput(ctx)
+					if createErr != nil {
+						// without creation data the mismatch is diagnosed from the runtime code alone
+						util.Logger().Warning(fmt.Sprintf("creation code of %s unavailable, runtime code of the compiled length not compared with it: %v", v.Address, createErr))
+						createData = ""
 					}
 					createData = util.TrimHex(createData)
 					fetchedCreateData = true
 				}
 				if len(createData) > 0 {
-					recompileBytesCodeWithLibraries := addLibraryAddresses(contract.Evm.Bytecode.Object, createData).Replaced
+					recompileBytesCodeWithLibraries, libraries := linkLibraries(util.TrimHex(contract.Evm.Bytecode.Object), createData, contract.Evm.Bytecode.LinkReferences, compiledOutput.Libraries)
 					encodedConstructorArgs := extractEncodedConstructorArgs(createData, recompileBytesCodeWithLibraries)
 					if strings.HasPrefix(createData, BytecodeWithoutMetadata(recompileBytesCodeWithLibraries)) {
-						compiledOutput.CompileTarget = compileTarget
-						compiledOutput.ContractName = contractName
-						return &Match{Status: perfect, ConstructorArgs: encodedConstructorArgs}, nil
+						argsDiffer = v.ConstructorArgs != "" && !strings.EqualFold(util.TrimHex(v.ConstructorArgs), util.TrimHex(encodedConstructorArgs))
+						if !argsDiffer {
+							compiledOutput.CompileTarget = compileTarget
+							compiledOutput.ContractName = contractName
+							return &Match{Status: perfect, ConstructorArgs: encodedConstructorArgs, Libraries: libraries}, nil
+						}
 					}
 				}
 			}
+			diagnostic := diagnoseMismatch(compileTarget+":"+contractName, util.TrimHex(linkedDeployCode), trimmedRawChainBytecode, contract, argsDiffer)
+			if createErr != nil && len(trimmedChainBytecode) == len(trimmedWithLibraries) {
+				diagnostic.Hints = append(diagnostic.Hints, fmt.Sprintf("the code has the compiled length but the creation code to compare with could not be read, set creationTx or retry: %v", createErr))
+			}
+			diagnostics = append(diagnostics, diagnostic)
 		}
 	}
-	return &Match{Status: mismatch}, nil
+	sortDiagnostics(diagnostics)
+	return &Match{Status: mismatch, Diagnostics: diagnostics}, nil
 }
 
-type addLibraryAddressesResult struct {
-	Replaced   string
-	LibraryMap map[string]string
+// checkConstructorArgs reads the constructor arguments from the creation code of a runtime match, when it can
+// be read, and tells whether they contradict the supplied constructorArgs. Supplied arguments never stand in
+// for the ones on chain, they only reject a contract they contradict, as they do when the creation code itself
+// is compared
+func (v *VerificationRequest) checkConstructorArgs(ctx context.Context, contract SolcContract, libraries map[string]string) (string, bool) {
+	createData, err := v.cachedCreationInput(ctx)
+	if err != nil {
+		util.Logger().Warning(fmt.Sprintf("creation code of %s unavailable, constructor arguments not read: %v", v.Address, err))
+		return "", false
+	}
+	createData = util.TrimHex(createData)
+	compiled, _ := linkLibraries(util.TrimHex(contract.Evm.Bytecode.Object), createData, contract.Evm.Bytecode.LinkReferences, libraries)
+	if createData == "" || !strings.HasPrefix(createData, BytecodeWithoutMetadata(compiled)) {
+		return "", false
+	}
+	constructorArgs := extractEncodedConstructorArgs(createData, compiled)
+	return constructorArgs, v.ConstructorArgs != "" && !strings.EqualFold(util.TrimHex(v.ConstructorArgs), util.TrimHex(constructorArgs))
 }
 
-func addLibraryAddresses(template, real string) addLibraryAddressesResult {
-	const placeholderStart = "__$"
-	const placeholderLength = 40
-	libraryMap := make(map[string]string)
-	replaced := template
-
-	for {
-		index := strings.Index(replaced, placeholderStart)
-		if index == -1 {
-			break
-		}
-		// Check if there's enough length left for a full placeholder
-		if index+placeholderLength > len(replaced) {
-			break
-		}
-		placeholder := replaced[index : index+placeholderLength]
-
-		// Ensure real has enough length at the current index
-		if index+placeholderLength > len(real) {
-			panic("real string length insufficient for placeholder")
+// compareCreationBytecodes compares the compiled creation code with the one the address was created with,
+// for addresses without code. Immutables are only set by the constructor and cannot be checked
+func (v *VerificationRequest) compareCreationBytecodes(compiledOutput *SolcOutput) *Match {
+	var diagnostics []MismatchDiagnostic
+	for compileTarget, contracts := range compiledOutput.candidates() {
+		for contractName, contract := range contracts {
+			if contract.Evm.Bytecode.Object == "" {
+				continue
+			}
+			compiled, libraries := linkLibraries(util.TrimHex(contract.Evm.Bytecode.Object), v.creationCode, contract.Evm.Bytecode.LinkReferences, compiledOutput.Libraries)
+			status := mismatch
+			switch {
+			case strings.HasPrefix(v.creationCode, compiled):
+				status = perfect
+			case len(v.creationCode) >= len(compiled) && strings.HasPrefix(v.creationCode, BytecodeWithoutMetadata(compiled)):
+				status = partial
+			}
+			constructorArgs := extractEncodedConstructorArgs(v.creationCode, compiled)
+			argsDiffer := v.ConstructorArgs != "" && !strings.EqualFold(util.TrimHex(v.ConstructorArgs), util.TrimHex(constructorArgs))
+			if status != mismatch && !argsDiffer {
+				compiledOutput.CompileTarget = compileTarget
+				compiledOutput.ContractName = contractName
+				return &Match{Status: status, ConstructorArgs: constructorArgs, Libraries: libraries, Creation: true}
+			}
+			diagnostics = append(diagnostics, diagnoseMismatch(compileTarget+":"+contractName, compiled, v.creationCode[:min(len(compiled), len(v.creationCode))], contract, status != mismatch))
 		}
-		address := real[index : index+placeholderLength]
-
-		// Store mapping
-		libraryMap[placeholder] = address
-
-		// Escape $ signs for regex
-		regexStr := strings.Replace(placeholder, "__$", "__\\$", -1)
-		regexStr = strings.Replace(regexStr, "$__", "\\$__", -1)
+	}
+	sortDiagnostics(diagnostics)
+	return &Match{Status: mismatch, Diagnostics: diagnostics, Creation: true}
+}
 
-		// Replace all occurrences
-		re := regexp.MustCompile(regexStr)
-		replaced = re.ReplaceAllString(replaced, address)
+// creationMetadata decodes the runtime metadata embedded at the end of the creation code part of the
+// on-chain creation data, before the constructor arguments
+func creationMetadata(creationCode, compiledCreation string) *BytecodeMetadata {
+	size := len(util.TrimHex(compiledCreation))
+	if size > len(creationCode) {
+		return nil
 	}
+	metadata, _ := DecodeBytecodeMetadata(creationCode[:size])
+	return metadata
+}
 
-	return addLibraryAddressesResult{Replaced: replaced, LibraryMap: libraryMap}
+// replaceImmutables copies the on-chain value of every immutable into the compiled runtime code,
+// solc leaves those ranges zeroed until the constructor runs. Both codes are hex without 0x
+func replaceImmutables(compiled, chain string, references map[string][]BytecodeRange) (string, map[string]string) {
+	if len(references) == 0 {
+		return compiled, nil
+	}
+	code := []byte(util.TrimHex(compiled))
+	immutables := make(map[string]string, len(references))
+	for id, ranges := range references {
+		for _, ref := range ranges {
+			start, end := ref.Start*2, (ref.Start+ref.Length)*2
+			if ref.Start < 0 || ref.Length <= 0 || end > len(code) || end > len(chain) {
+				continue
+			}
+			copy(code[start:end], chain[start:end])
+			immutables[id] = "0x" + chain[start:end]
+		}
+	}
+	return string(code), immutables
 }
 
 func extractEncodedConstructorArgs(creationData string, compiledCreationBytecode string) string {
 	startIndex := strings.Index(creationData, compiledCreationBytecode)
+	if startIndex < 0 {
+		// only the metadata hash differs, the CBOR blob keeps its length
+		startIndex = 0
+	}
 	if len(creationData) <= startIndex+len(compiledCreationBytecode) {
 		return ""
 	}
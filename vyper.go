@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"verify-golang/util"
+)
+
+const (
+	languageVyper = "Vyper"
+	// vyperImmutables keys the immutables vyper appends to the runtime code, they have no AST id
+	vyperImmutables = "immutables"
+)
+
+var (
+	ErrInvalidVyperVersion = errors.New("invalid vyper version, expected a release such as 0.3.10")
+
+	vyperVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+((a|b|rc)\d+)?$`)
+)
+
+// VyperMetadata is a vyper standard JSON input
+type VyperMetadata struct {
+	Language   string                     `json:"language"`
+	Sources    SourcesCode                `json:"sources"`
+	Interfaces map[string]json.RawMessage `json:"interfaces,omitempty"`
+	Settings   VyperSettings              `json:"settings"`
+	Extra      extraFields                `json:"-"`
+	// target and name select the contract to compare, name is the file name without its extension
+	target, name string
+}
+
+type VyperSettings struct {
+	EvmVersion string `json:"evmVersion,omitempty"`
+	// Optimize is a bool until 0.3.10, then "none", "gas" or "codesize"
+	Optimize        json.RawMessage `json:"optimize,omitempty"`
+	OutputSelection interface{}     `json:"outputSelection,omitempty"`
+	Extra           extraFields     `json:"-"`
+}
+
+func (s *VyperMetadata) UnmarshalJSON(data []byte) (err error) {
+	type plain VyperMetadata
+	s.Extra, err = unmarshalKnown(data, (*plain)(s))
+	return err
+}
+
+func (s VyperMetadata) MarshalJSON() ([]byte, error) {
+	type plain VyperMetadata
+	return marshalKnown(plain(s), s.Extra)
+}
+
+func (s *VyperSettings) UnmarshalJSON(data []byte) (err error) {
+	type plain VyperSettings
+	s.Extra, err = unmarshalKnown(data, (*plain)(s))
+	return err
+}
+
+func (s VyperSettings) MarshalJSON() ([]byte, error) {
+	type plain VyperSettings
+	return marshalKnown(plain(s), s.Extra)
+}
+
+func (s *VyperMetadata) String() string {
+	s.Settings.OutputSelection = map[string][]string{"*": {"abi", "evm.bytecode", "evm.deployedBytecode"}}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s *VyperMetadata) recompileContract(ctx context.Context, version string) (*SolcOutput, error) {
+	cmd := exec.CommandContext(ctx, VyperManagerInstance.path(version), "--standard-json")
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdin = strings.NewReader(s.String())
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("vyper failed: %v %s", err, stderrBuf.String())
+	}
+
+	var result SolcOutput
+	if err := json.Unmarshal(stdoutBuf.Bytes(), &result); err != nil {
+		return nil, err
+	}
+	for _, compileErr := range result.Errors {
+		if strings.EqualFold(compileErr.Severity, "error") {
+			if compileErr.FormattedMessage != "" {
+				return nil, errors.New(compileErr.FormattedMessage)
+			}
+			return nil, errors.New(compileErr.Message)
+		}
+	}
+	// vyper prefixes its code with 0x, solc does not
+	for _, contracts := range result.Contracts {
+		for name, contract := range contracts {
+			contract.Evm.Bytecode.Object = util.TrimHex(contract.Evm.Bytecode.Object)
+			contract.Evm.DeployedBytecode.Object = util.TrimHex(contract.Evm.DeployedBytecode.Object)
+			contracts[name] = contract
+		}
+	}
+	result.Language = languageVyper
+	result.CompileTarget, result.ContractName = s.target, s.name
+	return &result, nil
+}
+
+// verifyVyper accepts a vyper standard JSON input, ContractName selects the source as "path/Name.vy",
+// "path/Name.vy:Name" or is left out when there is a single source
+func (v *VerificationRequest) verifyVyper(input string) (IMetadata, error) {
+	var metadata VyperMetadata
+	if err := json.Unmarshal([]byte(input), &metadata); err != nil {
+		return nil, InvalidValidInputMetadata
+	}
+	if err := metadata.Sources.validate(); err != nil {
+		return nil, err
+	}
+	sourcePath, contractName := splitContractName(v.ContractName)
+	if sourcePath == "" && strings.HasSuffix(contractName, ".vy") {
+		sourcePath, contractName = contractName, ""
+	}
+	if sourcePath == "" {
+		if len(metadata.Sources) != 1 {
+			return nil, InvalidContractName
+		}
+		for path := range metadata.Sources {
+			sourcePath = path
+		}
+	}
+	if _, ok := metadata.Sources[sourcePath]; !ok {
+		return nil, fmt.Errorf("source %s not found in standard json input", sourcePath)
+	}
+	if contractName == "" {
+		contractName = strings.TrimSuffix(filepath.Base(sourcePath), filepath.Ext(sourcePath))
+	}
+	metadata.Language = languageVyper
+	metadata.target, metadata.name = sourcePath, contractName
+	return &metadata, nil
+}
+
+// vyperTrailer is the CBOR metadata vyper appends to its code. Until 0.3.9 the runtime code ends with
+// {"vyper": [major, minor, patch]}, from 0.3.10 the creation code ends with [runtime size, data section
+// sizes, immutables size, {"vyper": [...]}], preceded by an integrity hash from 0.4.1, and the length
+// suffix counts itself
+type vyperTrailer struct {
+	Version string
+	// Size is the length of the trailer in bytes, the two byte length suffix included
+	Size int
+	// ImmutablesSize is the length of the immutables appended to the runtime code, -1 when unknown
+	ImmutablesSize int
+}
+
+func decodeVyperTrailer(code []byte) (*vyperTrailer, bool) {
+	if len(code) < 2 {
+		return nil, false
+	}
+	n := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	for _, size := range []int{n + 2, n} {
+		if size <= 2 || size > len(code) {
+			continue
+		}
+		blob := code[len(code)-size : len(code)-2]
+		decoded, read, err := util.DecodeCBOR(blob)
+		if err != nil || read != len(blob) {
+			continue
+		}
+		trailer := vyperTrailer{Size: size, ImmutablesSize: -1}
+		fields, _ := decoded.(map[string]any)
+		if items, ok := decoded.([]any); ok && len(items) >= 4 {
+			fields, _ = items[len(items)-1].(map[string]any)
+			if immutables, ok := items[len(items)-2].(uint64); ok {
+				trailer.ImmutablesSize = int(immutables)
+			}
+		}
+		if trailer.Version = vyperVersionOf(fields["vyper"]); trailer.Version != "" {
+			return &trailer, true
+		}
+	}
+	return nil, false
+}
+
+// vyperVersionOf formats the [major, minor, patch] array of the trailer
+func vyperVersionOf(value any) string {
+	items, ok := value.([]any)
+	if !ok || len(items) != 3 {
+		return ""
+	}
+	parts := make([]string, 0, 3)
+	for _, item := range items {
+		number, ok := item.(uint64)
+		if !ok {
+			return ""
+		}
+		parts = append(parts, fmt.Sprint(number))
+	}
+	return strings.Join(parts, ".")
+}
+
+// withoutVyperTrailer strips the vyper trailer from hex code
+func withoutVyperTrailer(code string) string {
+	raw, err := hex.DecodeString(util.TrimHex(code))
+	if err != nil {
+		return code
+	}
+	trailer, ok := decodeVyperTrailer(raw)
+	if !ok {
+		return code
+	}
+	return hex.EncodeToString(raw[:len(raw)-trailer.Size])
+}
+
+// vyperImmutablesSize is the length in bytes of the immutables appended to the runtime code, as recorded by
+// the trailer of the creation code, -1 when unknown
+func vyperImmutablesSize(creation string) int {
+	raw, err := hex.DecodeString(util.TrimHex(creation))
+	if err != nil {
+		return -1
+	}
+	trailer, ok := decodeVyperTrailer(raw)
+	if !ok {
+		return -1
+	}
+	return trailer.ImmutablesSize
+}
+
+// compareVyperBytecodes compares vyper runtime code. The constructor appends the immutables to it, the
+// code then only starts with the compiled code, and the trailer only records the compiler version
+func (v *VerificationRequest) compareVyperBytecodes(chainBytecode string, compiledOutput *SolcOutput) *Match {
+	chain := strings.ToLower(util.TrimHex(chainBytecode))
+	var diagnostics []MismatchDiagnostic
+	for compileTarget, contracts := range compiledOutput.candidates() {
+		for contractName, contract := range contracts {
+			compiled := strings.ToLower(util.TrimHex(contract.Evm.DeployedBytecode.Object))
+			if compiled == "" {
+				continue
+			}
+			status := mismatch
+			var immutables map[string]string
+			unknownImmutables := false
+			switch {
+			case compiled == chain:
+				status = perfect
+			case strings.HasPrefix(chain, compiled):
+				// the appended bytes are immutables only when the creation code says that many are expected,
+				// before 0.3.10 it does not and any code could follow the compiled one
+				switch size := vyperImmutablesSize(contract.Evm.Bytecode.Object); {
+				case size < 0:
+					unknownImmutables = true
+				case size*2 == len(chain)-len(compiled):
+					status = perfect
+					immutables = map[string]string{vyperImmutables: "0x" + chain[len(compiled):]}
+				}
+			case withoutVyperTrailer(compiled) == withoutVyperTrailer(chain):
+				status = partial
+			}
+			if status != mismatch {
+				compiledOutput.CompileTarget = compileTarget
+				compiledOutput.ContractName = contractName
+				return &Match{Status: status, Immutables: immutables}
+			}
+			diagnostic := diagnoseMismatch(compileTarget+":"+contractName, compiled, chain, contract, false)
+			if unknownImmutables {
+				diagnostic.Region = regionImmutables
+				diagnostic.Hints = []string{fmt.Sprintf("the on-chain code appends %d bytes to the compiled code, this vyper version does not record the length of its immutables so they cannot be verified", (len(chain)-len(compiled))/2)}
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	sortDiagnostics(diagnostics)
+	return &Match{Status: mismatch, Diagnostics: diagnostics}
+}
+
+// checkVyperVersion defaults CompilerVersion to the vyper version of the on-chain trailer
+func (v *VerificationRequest) checkVyperVersion(version string) error {
+	if v.CompilerVersion == "" {
+		util.Logger().Info(fmt.Sprintf("detected vyper version %s for contract %s", version, v.Address))
+		v.CompilerVersion = "v" + version
+		return nil
+	}
+	if sent := shortVersion(v.CompilerVersion); sent != version {
+		return fmt.Errorf("compiler version mismatch: chain says vyper %s, you sent %s", version, sent)
+	}
+	return nil
+}
+
+// VyperManager keeps vyper release binaries next to solc in the static cache, as vyper-<version>
+type VyperManager struct {
+	versions   sync.Map
+	cacheDir   string
+	releaseURL string
+}
+
+var VyperManagerInstance *VyperManager
+
+const GithubVyperReleases = "https://api.github.com/repos/vyperlang/vyper/releases/tags/"
+
+func NewVyperManager(cacheDir string) *VyperManager {
+	return &VyperManager{cacheDir: cacheDir, releaseURL: GithubVyperReleases}
+}
+
+func (vm *VyperManager) path(version string) string {
+	return filepath.Join(vm.cacheDir, "vyper-"+shortVersion(version))
+}
+
+func (vm *VyperManager) EnsureVersion(version string) error {
+	if !vyperVersionRegex.MatchString(shortVersion(version)) {
+		return ErrInvalidVyperVersion
+	}
+	if _, ok := vm.versions.Load(version); ok {
+		return nil
+	}
+	path := vm.path(version)
+	return compilerDownloads.do("vyper", shortVersion(version), path, func(f *compilerFetch) error {
+		if _, err := os.Stat(path); err == nil {
+			vm.versions.Store(version, path)
+			return nil
+		}
+
+		if offline() {
+			return fmt.Errorf("%w: vyper %s is not cached in %s", ErrOffline, version, vm.cacheDir)
+		}
+		util.Logger().Info(fmt.Sprintf("Start Downloading vyper %s", version))
+		if err := vm.download(version, f); err != nil {
+			return err
+		}
+		vm.versions.Store(version, path)
+		return nil
+	})
+}
+
+func vyperPlatform() string {
+	if runtime.GOOS == "darwin" {
+		return ".darwin"
+	}
+	return ".linux"
+}
+
+// download fetches the release asset named like vyper.0.3.10+commit.91361694.linux
+func (vm *VyperManager) download(version string, progress *compilerFetch) error {
+	tag := "v" + shortVersion(version)
+	resp, err := http.Get(vm.releaseURL + tag)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch vyper release %s failed: %d", tag, resp.StatusCode)
+	}
+	var release Release
+	if err = json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return err
+	}
+	var downloadURL string
+	for _, asset := range release.Assets {
+		if strings.HasPrefix(asset.Name, "vyper.") && strings.HasSuffix(asset.Name, vyperPlatform()) {
+			downloadURL = asset.DownloadURL
+		}
+	}
+	if downloadURL == "" {
+		return fmt.Errorf("vyper release %s has no %s build", tag, runtime.GOOS)
+	}
+
+	util.Logger().Info(fmt.Sprintf("Downloading vyper from %s", downloadURL))
+	fileResp, err := http.Get(downloadURL)
+	if err != nil {
+		return err
+	}
+	defer fileResp.Body.Close()
+	if fileResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download vyper %s failed: %d", tag, fileResp.StatusCode)
+	}
+	progress.expect(fileResp.ContentLength)
+	tmp, err := os.CreateTemp(vm.cacheDir, ".tmp-vyper-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(io.MultiWriter(tmp, progress), fileResp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), vm.path(version))
+}
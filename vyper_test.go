@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// runtime code of vyper 0.3.7, ending with {"vyper": [0, 3, 7]}
+	testVyperRuntime037 = "600160025500" + "a165767970657283000307" + "000b"
+	// creation code trailer of vyper 0.3.10, [32, [], 64, {"vyper": [0, 3, 10]}]
+	testVyperTrailer0310 = "841820801840a1657679706572830003 0a0013"
+	// creation code trailer of vyper 0.4.1, preceded by the integrity hash
+	testVyperTrailer041 = "855820" + "1111111111111111111111111111111111111111111111111111111111111111" + "182080 1840a165767970657283000401 0035"
+)
+
+func Test_decodeVyperTrailer(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		version    string
+		size       int
+		immutables int
+	}{
+		{"0.3.7 runtime", testVyperRuntime037, "0.3.7", 13, -1},
+		{"0.3.10 creation", "6080" + testVyperTrailer0310, "0.3.10", 19, 64},
+		{"0.4.1 creation", "6080" + testVyperTrailer041, "0.4.1", 53, 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := hex.DecodeString(strings.ReplaceAll(tt.code, " ", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			trailer, ok := decodeVyperTrailer(code)
+			if !ok || trailer.Version != tt.version || trailer.Size != tt.size || trailer.ImmutablesSize != tt.immutables {
+				t.Errorf("decodeVyperTrailer() = %+v, %v", trailer, ok)
+			}
+		})
+	}
+
+	// solc metadata is no vyper trailer
+	solc, _ := hex.DecodeString("a264697066735822" + strings.Repeat("11", 34) + "64736f6c6343000813" + "0033")
+	if trailer, ok := decodeVyperTrailer(solc); ok {
+		t.Errorf("unexpected trailer %+v", trailer)
+	}
+	if metadata, err := DecodeBytecodeMetadata(testVyperRuntime037); err != nil || metadata.Vyper != "0.3.7" || metadata.Solc != "" {
+		t.Errorf("DecodeBytecodeMetadata() = %+v, %v", metadata, err)
+	}
+}
+
+func Test_checkVyperVersion(t *testing.T) {
+	metadata := &BytecodeMetadata{Vyper: "0.3.7"}
+	req := VerificationRequest{}
+	if err := req.checkCompilerVersion(metadata); err != nil || req.CompilerVersion != "v0.3.7" {
+		t.Errorf("checkCompilerVersion() = %v, %s", err, req.CompilerVersion)
+	}
+	req.CompilerVersion = "v0.3.9"
+	if err := req.checkCompilerVersion(metadata); err == nil || !strings.Contains(err.Error(), "vyper 0.3.7") {
+		t.Errorf("expected a version mismatch, got %v", err)
+	}
+}
+
+func testVyperInput(sources ...string) string {
+	input := map[string]any{"language": "Vyper", "sources": map[string]any{}, "settings": map[string]any{
+		"evmVersion": "shanghai", "optimize": "gas", "enable_decimals": true,
+	}}
+	for _, source := range sources {
+		input["sources"].(map[string]any)[source] = map[string]string{"content": "# @version 0.3.10\n"}
+	}
+	raw, _ := json.Marshal(input)
+	return string(raw)
+}
+
+func TestVerifyMetadataVyper(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          VerificationRequest
+		target, want string
+	}{
+		{"metadata single source", VerificationRequest{Metadata: testVyperInput("contracts/Token.vy")}, "contracts/Token.vy", "Token"},
+		{"standard json path", VerificationRequest{StandardJson: testVyperInput("contracts/Token.vy", "contracts/Vault.vy"), ContractName: "contracts/Vault.vy"}, "contracts/Vault.vy", "Vault"},
+		{"standard json name", VerificationRequest{StandardJson: testVyperInput("contracts/Token.vy", "contracts/Vault.vy"), ContractName: "contracts/Token.vy:Token"}, "contracts/Token.vy", "Token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := tt.req.VerifyMetadata()
+			if err != nil {
+				t.Fatal(err)
+			}
+			metadata, ok := input.(*VyperMetadata)
+			if !ok || metadata.target != tt.target || metadata.name != tt.want {
+				t.Fatalf("VerifyMetadata() = %#v", input)
+			}
+			// settings vyper has and this code does not model go through
+			var compilerInput map[string]map[string]any
+			_ = json.Unmarshal([]byte(metadata.String()), &compilerInput)
+			if compilerInput["settings"]["enable_decimals"] != true || compilerInput["settings"]["optimize"] != "gas" || compilerInput["settings"]["outputSelection"] == nil {
+				t.Errorf("unexpected compiler input %s", metadata.String())
+			}
+		})
+	}
+
+	req := VerificationRequest{StandardJson: testVyperInput("contracts/Token.vy", "contracts/Vault.vy")}
+	if _, err := req.VerifyMetadata(); err != InvalidContractName {
+		t.Errorf("expected InvalidContractName, got %v", err)
+	}
+}
+
+func Test_compareVyperBytecodes(t *testing.T) {
+	runtime := "600160025500"
+	// the creation code trailer expects 64 bytes of immutables
+	creation := "6080" + strings.ReplaceAll(testVyperTrailer0310, " ", "")
+	immutables := strings.Repeat("00", 63) + "ff"
+	tests := []struct {
+		name       string
+		compiled   string
+		chain      string
+		status     string
+		immutables map[string]string
+		region     string
+	}{
+		{"identical", testVyperRuntime037, testVyperRuntime037, perfect, nil, ""},
+		{"immutables", runtime, runtime + immutables, perfect, map[string]string{vyperImmutables: "0x" + immutables}, ""},
+		{"immutables of unknown length", testVyperRuntime037, testVyperRuntime037 + immutables, mismatch, nil, regionImmutables},
+		{"more data than immutables", runtime, runtime + immutables + "00", mismatch, nil, regionCode},
+		{"other patch version", testVyperRuntime037, runtime + "a165767970657283000308000b", partial, nil, ""},
+		{"other code", runtime, "600260025500", mismatch, nil, regionCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var contract SolcContract
+			contract.Evm.DeployedBytecode.Object = tt.compiled
+			if tt.compiled == runtime {
+				contract.Evm.Bytecode.Object = creation
+			}
+			output := &SolcOutput{Language: languageVyper, Contracts: map[string]map[string]SolcContract{"contracts/Token.vy": {"Token": contract}}}
+			req := VerificationRequest{}
+			match, err := req.compareBytecodes(context.Background(), "0x"+tt.chain, output)
+			if err != nil || match.Status != tt.status || fmt.Sprint(match.Immutables) != fmt.Sprint(tt.immutables) {
+				t.Fatalf("compareBytecodes() = %+v, %v", match, err)
+			}
+			if tt.status != mismatch && output.ContractName != "Token" {
+				t.Errorf("contract not selected")
+			}
+			if tt.status == mismatch && (len(match.Diagnostics) != 1 || match.Diagnostics[0].Region != tt.region) {
+				t.Errorf("expected a %s diagnostic, got %+v", tt.region, match.Diagnostics)
+			}
+		})
+	}
+}
+
+func Test_compareVyperBytecodesTarget(t *testing.T) {
+	var token, vault SolcContract
+	token.Evm.DeployedBytecode.Object = "600160025500"
+	vault.Evm.DeployedBytecode.Object = "600260025500"
+	output := &SolcOutput{Language: languageVyper, CompileTarget: "contracts/Token.vy", ContractName: "Token", Contracts: map[string]map[string]SolcContract{
+		"contracts/Token.vy": {"Token": token}, "contracts/Vault.vy": {"Vault": vault},
+	}}
+	req := VerificationRequest{}
+	match, err := req.compareBytecodes(context.Background(), "0x"+vault.Evm.DeployedBytecode.Object, output)
+	if err != nil || match.Status != mismatch || output.ContractName != "Token" {
+		t.Errorf("expected the requested Token to mismatch, got %+v in %s", match, output.ContractName)
+	}
+}
+
+func TestVyperManagerEnsureVersion(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tags/v0.3.10":
+			_ = json.NewEncoder(w).Encode(Release{TagName: "v0.3.10", Assets: []Asset{
+				{Name: "vyper.0.3.10+commit.91361694.windows.exe", DownloadURL: server.URL + "/windows"},
+				{Name: "vyper.0.3.10+commit.91361694.linux", DownloadURL: server.URL + "/binary"},
+				{Name: "vyper.0.3.10+commit.91361694.darwin", DownloadURL: server.URL + "/binary"},
+			}})
+		case "/binary":
+			_, _ = w.Write([]byte("#!/bin/sh\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	manager := NewVyperManager(t.TempDir())
+	manager.releaseURL = server.URL + "/tags/"
+	if err := manager.EnsureVersion("v0.3.10+commit.91361694"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(manager.cacheDir, "vyper-0.3.10"))
+	if err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("vyper binary not installed: %v", err)
+	}
+	if err = manager.EnsureVersion("v0.3.11"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a missing release, got %v", err)
+	}
+	if err = manager.EnsureVersion("v../../solc"); err != ErrInvalidVyperVersion {
+		t.Errorf("expected ErrInvalidVyperVersion, got %v", err)
+	}
+	entries, _ := os.ReadDir(manager.cacheDir)
+	if len(entries) != 1 {
+		t.Errorf("unexpected files left in the cache: %v", entries)
+	}
+}
+
+func TestVyperRecompileContract(t *testing.T) {
+	dir := t.TempDir()
+	output := `{"contracts":{"contracts/Token.vy":{"Token":{"abi":[],"evm":{"bytecode":{"object":"0x6080"},"deployedBytecode":{"object":"0x600160025500"}}}}}}`
+	script := "#!/bin/sh\ncat > " + filepath.Join(dir, "input.json") + "\necho '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, "vyper-0.3.10"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	previous := VyperManagerInstance
+	VyperManagerInstance = NewVyperManager(dir)
+	defer func() { VyperManagerInstance = previous }()
+
+	req := VerificationRequest{Metadata: testVyperInput("contracts/Token.vy")}
+	input, err := req.VerifyMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := input.recompileContract(context.Background(), "v0.3.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := result.Contracts["contracts/Token.vy"]["Token"]
+	if result.Language != languageVyper || result.ContractName != "Token" || contract.Evm.DeployedBytecode.Object != "600160025500" || contract.Evm.Bytecode.Object != "6080" {
+		t.Errorf("unexpected output %+v", result)
+	}
+	sent, _ := os.ReadFile(filepath.Join(dir, "input.json"))
+	if !strings.Contains(string(sent), `"language":"Vyper"`) {
+		t.Errorf("unexpected compiler input %s", sent)
+	}
+}